@@ -0,0 +1,98 @@
+package book
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// Aggregate rounds price on side to a multiple of tick, bids down and asks up, so a bucket never
+// shows a better price than the levels in it.
+func Aggregate(side string, price, tick decimal.Decimal) decimal.Decimal {
+	var n = price.Div(tick)
+	if side == Bids {
+		n = n.Floor()
+	} else {
+		n = n.Ceil()
+	}
+	return n.Mul(tick)
+}
+
+// aggregate calls f with the levels of side merged into buckets of tick, best first, until f
+// returns false. A tick that is not positive passes the levels through.
+func (d *Depth) aggregate(side string, tick decimal.Decimal, f func(level Level) (next bool)) {
+	if tick.Sign() <= 0 {
+		d.walk(side, func(price, size decimal.Decimal) bool {
+			return f(Level{Price: price, Size: size})
+		})
+		return
+	}
+	var bucket Level
+	var open, next = false, true
+	d.walk(side, func(price, size decimal.Decimal) bool {
+		price = Aggregate(side, price, tick)
+		if open && price.Equal(bucket.Price) {
+			bucket.Size = bucket.Size.Add(size)
+			return true
+		}
+		if open {
+			next = f(bucket)
+			if !next {
+				return false
+			}
+		}
+		bucket, open = Level{Price: price, Size: size}, true
+		return true
+	})
+	if open && next {
+		f(bucket)
+	}
+}
+
+// Levels returns up to levels of the best levels of each side aggregated at tick, best first.
+func (d *Depth) Levels(levels int, tick decimal.Decimal) (asks, bids []Level) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var read = func(side string) (l []Level) {
+		if levels <= 0 {
+			return
+		}
+		d.aggregate(side, tick, func(level Level) bool {
+			l = append(l, level)
+			return len(l) < levels
+		})
+		return
+	}
+	return read(Asks), read(Bids)
+}
+
+// ToL2Tick returns an L2 copy of the book with its levels aggregated at tick. It fails with
+// ErrPrice when tick is finer than PriceScale or a bucket price overflows.
+func (book *L3) ToL2Tick(tick decimal.Decimal) (bookL2 *L2, err error) {
+	if tick.Sign() > 0 {
+		_, err = priceTick(tick)
+		if err != nil {
+			return
+		}
+	}
+	book.m.RLock()
+	defer book.m.RUnlock()
+	var l2 = NewL2()
+	l2.Sequence = book.Sequence
+	l2.Time = book.Time
+	for _, side := range []string{Asks, Bids} {
+		var s = l2.side(side)
+		book.Depth.aggregate(side, tick, func(level Level) bool {
+			var t int64
+			t, err = priceTick(level.Price)
+			if err != nil {
+				return false
+			}
+			s.set(t, level.Price, level.Size)
+			return true
+		})
+		if err != nil {
+			return
+		}
+	}
+	bookL2 = l2
+	return
+}
@@ -0,0 +1,110 @@
+package book
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		side  string
+		price string
+		tick  string
+		want  string
+	}{
+		{side: Bids, price: "10.37", tick: "0.05", want: "10.35"},
+		{side: Asks, price: "10.37", tick: "0.05", want: "10.4"},
+		{side: Bids, price: "10.35", tick: "0.05", want: "10.35"},
+		{side: Asks, price: "10.35", tick: "0.05", want: "10.35"},
+		{side: Bids, price: "1234", tick: "100", want: "1200"},
+		{side: Asks, price: "1234", tick: "100", want: "1300"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s at %s", tt.side, tt.price, tt.tick), func(t *testing.T) {
+			var got = Aggregate(tt.side, decimal.RequireFromString(tt.price), decimal.RequireFromString(tt.tick))
+			if !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLevels(t *testing.T) {
+	var book = NewL3()
+	for i, o := range [][3]string{
+		{Bids, "10.01", "1"}, {Bids, "10.04", "2"}, {Bids, "9.97", "3"},
+		{Asks, "10.06", "1"}, {Asks, "10.09", "2"}, {Asks, "10.11", "4"},
+	} {
+		if err := book.Add(fmt.Sprint(i), o[0], o[1], o[2], "1600000000000"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name   string
+		levels int
+		tick   string
+		asks   string
+		bids   string
+	}{
+		{name: "passed through", levels: 10, tick: "0", asks: "[10.06:1 10.09:2 10.11:4]", bids: "[10.04:2 10.01:1 9.97:3]"},
+		{name: "merged", levels: 10, tick: "0.05", asks: "[10.1:3 10.15:4]", bids: "[10:3 9.95:3]"},
+		{name: "limited", levels: 1, tick: "0.05", asks: "[10.1:3]", bids: "[10:3]"},
+		{name: "none", levels: 0, tick: "0.05", asks: "[]", bids: "[]"},
+	}
+	var format = func(levels []Level) string {
+		var s []string
+		for _, level := range levels {
+			s = append(s, level.Price.String()+":"+level.Size.String())
+		}
+		return fmt.Sprint(s)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asks, bids := book.Levels(tt.levels, decimal.RequireFromString(tt.tick))
+			if format(asks) != tt.asks || format(bids) != tt.bids {
+				t.Fatalf("asks = %s, bids = %s, want %s and %s", format(asks), format(bids), tt.asks, tt.bids)
+			}
+			if tt.levels < 10 {
+				return
+			}
+			l2, err := book.ToL2Tick(decimal.RequireFromString(tt.tick))
+			if err != nil {
+				t.Fatal(err)
+			}
+			asks, bids = l2.Levels(tt.levels, decimal.Zero)
+			if format(asks) != tt.asks || format(bids) != tt.bids {
+				t.Fatalf("l2 asks = %s, bids = %s, want %s and %s", format(asks), format(bids), tt.asks, tt.bids)
+			}
+		})
+	}
+}
+
+func TestToL2TickPrice(t *testing.T) {
+	var book = NewL3()
+	if err := book.Add("a", Bids, "10.01", "1", "1600000000000"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		tick    string
+		wantErr error
+	}{
+		{name: "representable", tick: "0.05"},
+		{name: "passed through", tick: "0"},
+		{name: "finer than the scale", tick: "0.00000000001", wantErr: ErrPrice},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l2, err := book.ToL2Tick(decimal.RequireFromString(tt.tick))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if (l2 == nil) != (tt.wantErr != nil) {
+				t.Fatalf("book = %v with err %v", l2, err)
+			}
+		})
+	}
+}
@@ -1,52 +1,65 @@
 package book
 
 import (
+	"encoding/json"
+	"sync"
 	"time"
 
 	"github.com/shopspring/decimal"
-
-	rbt "github.com/emirpasic/gods/trees/redblacktree"
 )
 
 type sideL2 struct {
-	tree *rbt.Tree
+	ladder
 }
 
-func newSideL2(tree *rbt.Tree) *sideL2 {
-	return &sideL2{tree: tree}
+func newSideL2(name string) *sideL2 {
+	return &sideL2{ladder: newLadder(name)}
 }
 
-func (side *sideL2) math(price decimal.Decimal, math func(oldSize decimal.Decimal) (newSize decimal.Decimal)) {
-	order := &OrderL2{Price: price}
-	v, found := side.tree.Get(order)
-	if found {
-		order = v.(*OrderL2)
-	}
-	order.Size = math(order.Size)
-	if order.Size.IsZero() {
-		side.tree.Remove(order)
-	} else {
-		side.tree.Put(order, order)
+// set sets the size of the level at price, whose tick is t.
+func (side *sideL2) set(t int64, price, size decimal.Decimal) (total decimal.Decimal) {
+	if size.IsZero() {
+		if lv, found := side.get(t); found {
+			side.remove(lv)
+		}
+		return
 	}
+	side.level(t, price).size = size
+	return size
 }
 
-func (side *sideL2) add(price, size decimal.Decimal) {
-	side.math(price, func(oldSize decimal.Decimal) (newSize decimal.Decimal) {
-		return oldSize.Add(size)
-	})
-}
-
-func (side *sideL2) sub(price, size decimal.Decimal) {
-	side.math(price, func(oldSize decimal.Decimal) (newSize decimal.Decimal) {
-		return oldSize.Sub(size)
-	})
-}
-
-func (side *sideL2) iterator() rbt.Iterator {
-	return side.tree.Iterator()
+func (side *sideL2) UnmarshalJSON(b []byte) (err error) {
+	var s [][2]string
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return
+	}
+	for _, v := range s {
+		var price, size decimal.Decimal
+		price, err = decimal.NewFromString(v[0])
+		if err != nil {
+			return
+		}
+		size, err = decimal.NewFromString(v[1])
+		if err != nil {
+			return
+		}
+		var t int64
+		t, err = parseTick(v[0], price)
+		if err != nil {
+			return
+		}
+		side.set(t, price, size)
+	}
+	return
 }
 
 type L2 struct {
+	m sync.RWMutex
+	Depth
+	notifier
+	guard *Guard
+
 	Sequence Sequence `json:"sequence"`
 	Bids     *sideL2  `json:"bids"`
 	Asks     *sideL2  `json:"asks"`
@@ -54,25 +67,151 @@ type L2 struct {
 }
 
 func NewL2() (book *L2) {
-	return &L2{
+	book = &L2{
 		Sequence: 0,
-		Asks:     newSideL2(rbt.NewWith(orderL2AsksCmp)),
-		Bids:     newSideL2(rbt.NewWith(orderL2BidsCmp)),
+		Asks:     newSideL2(Asks),
+		Bids:     newSideL2(Bids),
 		Time:     time.Now().UnixNano(),
 	}
+	book.Depth = Depth{lock: book.m.RLocker(), walk: book.walk}
+	return
+}
+
+func (book *L2) walk(side string, f func(price, size decimal.Decimal) (next bool)) {
+	var s = book.side(side)
+	if s == nil {
+		return
+	}
+	s.walk(func(lv *priceLevel) bool {
+		return f(lv.price, lv.size)
+	})
+}
+
+func (book *L2) side(name string) *sideL2 {
+	switch name {
+	case Bids:
+		return book.Bids
+	case Asks:
+		return book.Asks
+	}
+	return nil
+}
+
+func (book *L2) unlock() {
+	book.notifier.commit(&book.m, book.Sequence, book.Time, &book.Depth)
+}
+
+func (book *L2) GetSequence() Sequence {
+	book.m.RLock()
+	defer book.m.RUnlock()
+	return book.Sequence
+}
+
+func (book *L2) SetSequence(s Sequence) {
+	book.m.Lock()
+	defer book.m.Unlock()
+	book.Sequence = s
+}
+
+func (book *L2) Set(side, price, size string) (err error) {
+	book.m.Lock()
+	defer book.unlock()
+	err = book.set(side, price, size)
+	return
+}
+
+func (book *L2) set(side, price, size string) (err error) {
+	var p, s decimal.Decimal
+	p, err = decimal.NewFromString(price)
+	if err != nil {
+		return
+	}
+	s, err = decimal.NewFromString(size)
+	if err != nil {
+		return
+	}
+	var t int64
+	t, err = parseTick(price, p)
+	if err != nil {
+		return
+	}
+	if v := book.side(side); v != nil {
+		err = book.check(side, p, s)
+		if err != nil {
+			return
+		}
+		book.notifier.level(side, p, v.set(t, p, s))
+	}
+	return
+}
+
+func (book *L2) replace(from *L2) {
+	book.m.Lock()
+	defer book.unlock()
+	book.Sequence = from.Sequence
+	book.Bids = from.Bids
+	book.Asks = from.Asks
+	book.Time = from.Time
+	book.notifier.reset()
+	for _, side := range []string{Bids, Asks} {
+		book.walk(side, func(price, size decimal.Decimal) bool {
+			book.notifier.level(side, price, size)
+			return true
+		})
+	}
+}
+
+// OnReset clears the book so an L2 can mirror the level stream of an L3 it observes.
+func (book *L2) OnReset(sequence Sequence) {
+	book.m.Lock()
+	defer book.unlock()
+	book.Sequence = sequence
+	book.Asks = newSideL2(Asks)
+	book.Bids = newSideL2(Bids)
+	book.Time = time.Now().UnixNano()
+	book.notifier.reset()
+}
+
+func (book *L2) OnLevel(update LevelUpdate) {
+	book.m.Lock()
+	defer book.unlock()
+	if v := book.side(update.Side); v != nil && book.check(update.Side, update.Price, update.Size) == nil {
+		if t, err := priceTick(update.Price); err == nil {
+			book.notifier.level(update.Side, update.Price, v.set(t, update.Price, update.Size))
+		}
+	}
+	if update.Sequence > book.Sequence {
+		book.Sequence = update.Sequence
+	}
+}
+
+func (book *L2) OnTop(Top) {}
+
+func (book *L2) OnTrade(Trade) {}
+
+// AppendLevels appends up to levels of the best levels of each side to asks and bids, best first,
+// allocating nothing when they have the capacity.
+func (book *L2) AppendLevels(asks, bids []Level, levels int) ([]Level, []Level) {
+	book.m.RLock()
+	defer book.m.RUnlock()
+	return book.Asks.append(asks, levels), book.Bids.append(bids, levels)
 }
 
 func (book *L2) Object(level int) (asks, bids []interface{}) {
-	var i, j int
+	book.m.RLock()
+	defer book.m.RUnlock()
 	asks = make([]interface{}, level)
 	bids = make([]interface{}, level)
-	asksIterator := book.Asks.iterator()
-	for ; asksIterator.Next() && i < level; i++ {
-		asks[level-i-1] = asksIterator.Value()
-	}
-	bidsIterator := book.Bids.iterator()
-	for ; bidsIterator.Next() && j < level; j++ {
-		bids[j] = bidsIterator.Value()
-	}
+	var i, j int
+	book.Asks.walk(func(lv *priceLevel) bool {
+		asks[level-i-1] = &OrderL2{Price: lv.price, Size: lv.size}
+		i++
+		return i < level
+	})
+	book.Bids.walk(func(lv *priceLevel) bool {
+		bids[j] = &OrderL2{Price: lv.price, Size: lv.size}
+		j++
+		return j < level
+	})
 	return
 }
@@ -6,49 +6,56 @@ import (
 	"time"
 
 	"github.com/shopspring/decimal"
-
-	rbt "github.com/emirpasic/gods/trees/redblacktree"
 )
 
 type sideL3 struct {
-	name   string
-	tree   *rbt.Tree
+	ladder
 	orders map[string]*OrderL3
 }
 
-func newSideL3(name string, tree *rbt.Tree, orders map[string]*OrderL3) *sideL3 {
+func newSideL3(name string, orders map[string]*OrderL3) *sideL3 {
 	return &sideL3{
-		name:   name,
-		tree:   tree,
+		ladder: newLadder(name),
 		orders: orders,
 	}
 }
 
-func (side *sideL3) put(o *OrderL3) {
+func (side *sideL3) put(o *OrderL3) (total decimal.Decimal) {
+	if old, found := side.orders[o.Id]; found && old.Side == side.name {
+		side.del(old)
+	}
 	side.orders[o.Id] = o
-	side.tree.Put(o, o)
+	var lv = side.level(o.tick, o.Price)
+	lv.push(o)
+	return lv.size
 }
 
-func (side *sideL3) del(o *OrderL3) {
+func (side *sideL3) del(o *OrderL3) (total decimal.Decimal) {
 	delete(side.orders, o.Id)
-	side.tree.Remove(o)
-	return
-}
-
-func (side *sideL3) update(o *OrderL3) {
-	v, found := side.tree.Get(o)
-	if !found {
+	var lv = o.level
+	if lv == nil {
 		return
 	}
-	item := v.(*OrderL3)
-	item.Size = o.Size
-	if item.Size.IsZero() {
-		side.tree.Remove(item)
+	lv.remove(o)
+	if lv.count == 0 {
+		side.remove(lv)
+		return
 	}
+	return lv.size
 }
 
-func (side *sideL3) iterator() rbt.Iterator {
-	return side.tree.Iterator()
+func (side *sideL3) resize(o *OrderL3, size decimal.Decimal) (total decimal.Decimal) {
+	var lv = o.level
+	if lv == nil {
+		o.Size = size
+		return
+	}
+	lv.size = lv.size.Add(size.Sub(o.Size))
+	if o.own {
+		lv.own = lv.own.Add(size.Sub(o.Size))
+	}
+	o.Size = size
+	return lv.size
 }
 
 func (side *sideL3) UnmarshalJSON(b []byte) (err error) {
@@ -69,8 +76,15 @@ func (side *sideL3) UnmarshalJSON(b []byte) (err error) {
 }
 
 type L3 struct {
-	m      sync.RWMutex
-	orders map[string]*OrderL3
+	m sync.RWMutex
+	Depth
+	notifier
+	orders  map[string]*OrderL3
+	pending map[string]*ReceivedL3
+	filled  map[string]struct{} // makers a match emptied, until their done
+	own     map[string]bool
+	market  Depth
+	guard   *Guard
 
 	Sequence Sequence `json:"sequence"`
 	Bids     *sideL3  `json:"bids"`
@@ -80,13 +94,43 @@ type L3 struct {
 
 func NewL3() (book *L3) {
 	orders := make(map[string]*OrderL3)
-	return &L3{
+	book = &L3{
 		orders:   orders,
+		pending:  make(map[string]*ReceivedL3),
+		filled:   make(map[string]struct{}),
+		own:      make(map[string]bool),
 		Sequence: 0,
-		Asks:     newSideL3(Asks, rbt.NewWith(orderL3AsksCmp), orders),
-		Bids:     newSideL3(Bids, rbt.NewWith(orderL3BidsCmp), orders),
+		Asks:     newSideL3(Asks, orders),
+		Bids:     newSideL3(Bids, orders),
 		Time:     time.Now().UnixNano(),
 	}
+	book.Depth = Depth{lock: book.m.RLocker(), walk: book.walk}
+	book.market = Depth{lock: book.m.RLocker(), walk: book.walkMarket}
+	return
+}
+
+func (book *L3) walk(side string, f func(price, size decimal.Decimal) (next bool)) {
+	var s = book.side(side)
+	if s == nil {
+		return
+	}
+	s.walk(func(lv *priceLevel) bool {
+		return f(lv.price, lv.size)
+	})
+}
+
+func (book *L3) side(name string) *sideL3 {
+	switch name {
+	case Bids:
+		return book.Bids
+	case Asks:
+		return book.Asks
+	}
+	return nil
+}
+
+func (book *L3) unlock() {
+	book.notifier.commit(&book.m, book.Sequence, book.Time, &book.Depth)
 }
 
 func (book *L3) GetSequence() Sequence {
@@ -101,9 +145,36 @@ func (book *L3) SetSequence(s Sequence) {
 	book.Sequence = s
 }
 
+func (book *L3) replace(from *L3) {
+	book.m.Lock()
+	defer book.unlock()
+	book.orders = from.orders
+	book.pending = from.pending
+	book.filled = make(map[string]struct{})
+	book.Sequence = from.Sequence
+	book.Bids = from.Bids
+	book.Asks = from.Asks
+	book.Time = from.Time
+	for id := range book.own {
+		book.tag(id)
+	}
+	book.notifier.reset()
+	for _, side := range []*sideL3{book.Bids, book.Asks} {
+		side.walk(func(lv *priceLevel) bool {
+			book.notifier.level(side.name, lv.price, lv.size)
+			return true
+		})
+	}
+}
+
 func (book *L3) Add(id, side, price, size, timestamp string) (err error) {
 	book.m.Lock()
-	defer book.m.Unlock()
+	defer book.unlock()
+	err = book.add(id, side, price, size, timestamp)
+	return
+}
+
+func (book *L3) add(id, side, price, size, timestamp string) (err error) {
 	if size == "0" || price == "" {
 		return
 	}
@@ -112,28 +183,40 @@ func (book *L3) Add(id, side, price, size, timestamp string) (err error) {
 	if err != nil {
 		return
 	}
-	switch order.Side {
-	case Bids:
-		book.Bids.put(order)
-	case Asks:
-		book.Asks.put(order)
+	order.own = book.own[id]
+	if s := book.side(order.Side); s != nil {
+		err = book.check(order)
+		if err != nil {
+			return
+		}
+		// deleting a duplicate drops its own tag, which the order replacing it keeps.
+		book.del(order.Id)
+		if order.own {
+			book.own[id] = true
+		}
+		book.notifier.level(s.name, order.Price, s.put(order))
 	}
 	return
 }
 
 func (book *L3) Del(orderId string) {
 	book.m.Lock()
-	defer book.m.Unlock()
+	defer book.unlock()
+	book.del(orderId)
+}
+
+func (book *L3) del(orderId string) (found bool) {
 	order, found := book.orders[orderId]
 	if !found {
 		return
 	}
-	switch order.Side {
-	case Bids:
-		book.Bids.del(order)
-	case Asks:
-		book.Asks.del(order)
+	if order.own {
+		delete(book.own, orderId)
+	}
+	if s := book.side(order.Side); s != nil {
+		book.notifier.level(s.name, order.Price, s.del(order))
 	}
+	return
 }
 
 func (book *L3) Get(orderId string) (order *OrderL3, found bool) {
@@ -145,39 +228,35 @@ func (book *L3) Get(orderId string) (order *OrderL3, found bool) {
 
 func (book *L3) NewSize(orderId, newSize string) (err error) {
 	book.m.Lock()
-	defer book.m.Unlock()
+	defer book.unlock()
+	_, err = book.newSize(orderId, newSize)
+	return
+}
+
+func (book *L3) newSize(orderId, newSize string) (found bool, err error) {
 	var order *OrderL3
-	var found bool
 	order, found = book.orders[orderId]
 	if !found {
 		return
 	}
-	order.Size, err = decimal.NewFromString(newSize)
+	var size decimal.Decimal
+	size, err = decimal.NewFromString(newSize)
 	if err != nil {
 		return
 	}
-	switch order.Side {
-	case Bids:
-		if order.Size.IsZero() {
-			book.Bids.del(order)
-		} else {
-			book.Bids.update(order)
-		}
-	case Asks:
-		if order.Size.IsZero() {
-			book.Asks.del(order)
-		} else {
-			book.Asks.update(order)
-		}
-	}
+	err = book.resize(order, size)
 	return
 }
 
 func (book *L3) SubSize(orderId, subSize string) (err error) {
 	book.m.Lock()
-	defer book.m.Unlock()
+	defer book.unlock()
+	_, err = book.subSize(orderId, subSize)
+	return
+}
+
+func (book *L3) subSize(orderId, subSize string) (found bool, err error) {
 	var order *OrderL3
-	var found bool
 	order, found = book.orders[orderId]
 	if !found {
 		return
@@ -187,56 +266,64 @@ func (book *L3) SubSize(orderId, subSize string) (err error) {
 	if err != nil {
 		return
 	}
-	order.Size = order.Size.Sub(size)
-	switch order.Side {
-	case Bids:
-		if order.Size.IsZero() {
-			book.Bids.del(order)
-		} else {
-			book.Bids.update(order)
-		}
-	case Asks:
-		if order.Size.IsZero() {
-			book.Asks.del(order)
-		} else {
-			book.Asks.update(order)
+	err = book.resize(order, order.Size.Sub(size))
+	return
+}
+
+func (book *L3) resize(order *OrderL3, size decimal.Decimal) (err error) {
+	var s = book.side(order.Side)
+	if s == nil {
+		return
+	}
+	if book.guard != nil {
+		err = book.guard.negative(book.Sequence, s.name, order.Id, order.Price, size)
+		if err != nil {
+			return
 		}
 	}
+	if size.IsZero() {
+		book.del(order.Id)
+		return
+	}
+	book.notifier.level(s.name, order.Price, s.resize(order, size))
 	return
 }
 
 func (book *L3) Object(level int) (asks, bids []interface{}) {
 	book.m.RLock()
 	defer book.m.RUnlock()
-	var i, j int
 	asks = make([]interface{}, level)
 	bids = make([]interface{}, level)
-	asksIterator := book.Asks.iterator()
-	for ; asksIterator.Next() && i < level; i++ {
-		asks[level-i-1] = asksIterator.Value()
-	}
-	bidsIterator := book.Bids.iterator()
-	for ; bidsIterator.Next() && j < level; j++ {
-		bids[j] = bidsIterator.Value()
-	}
+	var i, j int
+	book.Asks.walk(func(lv *priceLevel) bool {
+		for o := lv.head; o != nil && i < level; o = o.next {
+			asks[level-i-1] = o
+			i++
+		}
+		return i < level
+	})
+	book.Bids.walk(func(lv *priceLevel) bool {
+		for o := lv.head; o != nil && j < level; o = o.next {
+			bids[j] = o
+			j++
+		}
+		return j < level
+	})
 	return
 }
 
-func (book *L3) ToL2() (bookL2 *L2) {
+// AppendLevels appends up to levels of the best price levels of each side to asks and bids, best
+// first. Levels are kept as orders change, so this reads the L2 view of the book in O(levels) and
+// allocates nothing when asks and bids have the capacity; prefer it to ToL2 for repeated reads.
+func (book *L3) AppendLevels(asks, bids []Level, levels int) ([]Level, []Level) {
 	book.m.RLock()
 	defer book.m.RUnlock()
-	bookL2 = NewL2()
-	bookL2.Sequence = book.Sequence
-	bookL2.Time = book.Time
-	asksIterator := book.Asks.iterator()
-	for asksIterator.Next() {
-		order := asksIterator.Value().(*OrderL3)
-		bookL2.Asks.add(order.Price, order.Size)
-	}
-	bidsIterator := book.Bids.iterator()
-	for bidsIterator.Next() {
-		order := bidsIterator.Value().(*OrderL3)
-		bookL2.Bids.add(order.Price, order.Size)
-	}
+	return book.Asks.append(asks, levels), book.Bids.append(bids, levels)
+}
+
+// ToL2 returns a copy of the book as an L2, for callers that need a book of their own.
+func (book *L3) ToL2() (bookL2 *L2) {
+	// levels passed through keep the ticks they rest at, so the copy cannot fail.
+	bookL2, _ = book.ToL2Tick(decimal.Zero)
 	return
 }
@@ -0,0 +1,314 @@
+package book
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/bzeron/mk/kucoin"
+)
+
+const (
+	MinInterval = time.Second
+	MaxInterval = 7 * 24 * time.Hour
+
+	candlesLimit = 1500
+)
+
+// weekStart is a Monday 00:00 UTC. Bars are aligned to it rather than to the zero time, so that
+// weekly bars open on Monday as the exchange's do.
+var weekStart = time.Date(1970, time.January, 5, 0, 0, 0, 0, time.UTC).UnixNano()
+
+var (
+	CandleSize = 1 << 12
+
+	ErrInterval = errors.New("book unsupported candle interval")
+
+	// klineTypes maps intervals to the type parameter of /api/v1/market/candles.
+	klineTypes = map[time.Duration]string{
+		time.Minute:        "1min",
+		3 * time.Minute:    "3min",
+		5 * time.Minute:    "5min",
+		15 * time.Minute:   "15min",
+		30 * time.Minute:   "30min",
+		time.Hour:          "1hour",
+		2 * time.Hour:      "2hour",
+		4 * time.Hour:      "4hour",
+		6 * time.Hour:      "6hour",
+		8 * time.Hour:      "8hour",
+		12 * time.Hour:     "12hour",
+		24 * time.Hour:     "1day",
+		7 * 24 * time.Hour: "1week",
+	}
+)
+
+type (
+	Candle struct {
+		Time     time.Time       `json:"time"`
+		Open     decimal.Decimal `json:"open"`
+		High     decimal.Decimal `json:"high"`
+		Low      decimal.Decimal `json:"low"`
+		Close    decimal.Decimal `json:"close"`
+		Volume   decimal.Decimal `json:"volume"`
+		Turnover decimal.Decimal `json:"turnover"`
+		Count    int             `json:"count"`
+	}
+
+	// Candles builds OHLCV bars of one interval from the trades of an L3 it observes. Intervals
+	// without trades become empty bars at the previous close. The last bar is the open one; closed
+	// bars are handed to onClose.
+	Candles struct {
+		m        sync.RWMutex
+		interval time.Duration
+		size     int
+		bars     []Candle
+		onClose  func(candle Candle)
+	}
+)
+
+func NewCandles(interval time.Duration, size int, onClose func(candle Candle)) (candles *Candles, err error) {
+	if interval < MinInterval || interval > MaxInterval || interval%MinInterval != 0 {
+		err = fmt.Errorf("%w: %s", ErrInterval, interval)
+		return
+	}
+	if size <= 0 {
+		size = CandleSize
+	}
+	candles = &Candles{interval: interval, size: size, onClose: onClose}
+	return
+}
+
+func (candles *Candles) Interval() time.Duration {
+	return candles.interval
+}
+
+func (candles *Candles) OnReset(Sequence) {}
+
+func (candles *Candles) OnTop(Top) {}
+
+func (candles *Candles) OnLevel(LevelUpdate) {}
+
+func (candles *Candles) OnTrade(trade Trade) {
+	candles.m.Lock()
+	var closed = candles.add(time.Unix(0, trade.Time), trade.Price, trade.Size)
+	candles.m.Unlock()
+	candles.closed(closed)
+}
+
+// Flush closes the open bar and adds empty bars up to the interval holding now, for callers that
+// need bars to close on time when no trade arrives.
+func (candles *Candles) Flush(now time.Time) {
+	candles.m.Lock()
+	var closed = candles.roll(candles.start(now))
+	candles.m.Unlock()
+	candles.closed(closed)
+}
+
+func (candles *Candles) closed(closed []Candle) {
+	if candles.onClose == nil {
+		return
+	}
+	for _, candle := range closed {
+		candles.onClose(candle)
+	}
+}
+
+// start returns the start of the interval holding t.
+func (candles *Candles) start(t time.Time) time.Time {
+	var offset = (t.UnixNano() - weekStart) % int64(candles.interval)
+	if offset < 0 {
+		offset += int64(candles.interval)
+	}
+	return t.Round(0).Add(-time.Duration(offset))
+}
+
+// roll opens bars up to start, returning the bars it closed. A gap longer than the retention only
+// builds the empty bars that are retained.
+func (candles *Candles) roll(start time.Time) (closed []Candle) {
+	if len(candles.bars) == 0 {
+		return
+	}
+	if last := candles.bars[len(candles.bars)-1]; start.Sub(last.Time)/candles.interval > time.Duration(candles.size) {
+		closed = append(closed, last)
+		candles.bars = append(candles.bars[:0:0], empty(last, start.Add(-time.Duration(candles.size)*candles.interval)))
+	}
+	for last := candles.bars[len(candles.bars)-1]; last.Time.Before(start); last = candles.bars[len(candles.bars)-1] {
+		closed = append(closed, last)
+		candles.bars = append(candles.bars, empty(last, last.Time.Add(candles.interval)))
+	}
+	candles.trim()
+	return
+}
+
+// empty returns a bar at t without trades at the close of last.
+func empty(last Candle, t time.Time) Candle {
+	return Candle{Time: t, Open: last.Close, High: last.Close, Low: last.Close, Close: last.Close}
+}
+
+func (candles *Candles) trim() {
+	if drop := len(candles.bars) - candles.size; drop > 0 {
+		candles.bars = append(candles.bars[:0:0], candles.bars[drop:]...)
+	}
+}
+
+func (candles *Candles) add(t time.Time, price, size decimal.Decimal) (closed []Candle) {
+	var start = candles.start(t)
+	if len(candles.bars) == 0 {
+		candles.bars = append(candles.bars, Candle{Time: start, Open: price, High: price, Low: price, Close: price})
+	} else {
+		closed = candles.roll(start)
+	}
+	var i = candles.search(start)
+	if i == len(candles.bars) || !candles.bars[i].Time.Equal(start) {
+		return
+	}
+	var bar = &candles.bars[i]
+	if bar.Count == 0 && bar.Volume.IsZero() {
+		bar.Open, bar.High, bar.Low = price, price, price
+	}
+	bar.High = decimal.Max(bar.High, price)
+	bar.Low = decimal.Min(bar.Low, price)
+	if i == len(candles.bars)-1 {
+		bar.Close = price
+	}
+	bar.Volume = bar.Volume.Add(size)
+	bar.Turnover = bar.Turnover.Add(size.Mul(price))
+	bar.Count++
+	return
+}
+
+func (candles *Candles) search(t time.Time) int {
+	return sort.Search(len(candles.bars), func(i int) bool {
+		return !candles.bars[i].Time.Before(t)
+	})
+}
+
+// Bars returns the retained bars, oldest first, ending with the open bar.
+func (candles *Candles) Bars() []Candle {
+	candles.m.RLock()
+	defer candles.m.RUnlock()
+	return append([]Candle(nil), candles.bars...)
+}
+
+// Last returns up to n of the latest bars, oldest first.
+func (candles *Candles) Last(n int) []Candle {
+	candles.m.RLock()
+	defer candles.m.RUnlock()
+	if n > len(candles.bars) {
+		n = len(candles.bars)
+	}
+	return append([]Candle(nil), candles.bars[len(candles.bars)-n:]...)
+}
+
+// Backfill merges historical bars of the same interval. They replace bars built from trades for
+// every interval before the open one, which may have been started mid-interval.
+func (candles *Candles) Backfill(history []Candle) {
+	candles.m.Lock()
+	defer candles.m.Unlock()
+	var bars = make(map[int64]Candle, len(candles.bars)+len(history))
+	for _, bar := range candles.bars {
+		bars[bar.Time.UnixNano()] = bar
+	}
+	var open time.Time
+	if len(candles.bars) > 0 {
+		open = candles.bars[len(candles.bars)-1].Time
+	}
+	for _, bar := range history {
+		bar.Time = candles.start(bar.Time)
+		if !open.IsZero() && !bar.Time.Before(open) {
+			continue
+		}
+		bars[bar.Time.UnixNano()] = bar
+	}
+	candles.bars = candles.bars[:0]
+	for _, bar := range bars {
+		candles.bars = append(candles.bars, bar)
+	}
+	sort.Slice(candles.bars, func(i, j int) bool {
+		return candles.bars[i].Time.Before(candles.bars[j].Time)
+	})
+	candles.trim()
+}
+
+// FetchCandles reads bars of interval in [from, to) from /api/v1/market/candles, oldest first.
+// Only the intervals the exchange serves are accepted.
+func FetchCandles(client *kucoin.Client, symbol string, interval time.Duration, from, to time.Time) (candles []Candle, err error) {
+	kind, ok := klineTypes[interval]
+	if !ok {
+		err = fmt.Errorf("%w: %s", ErrInterval, interval)
+		return
+	}
+	var seen = make(map[int64]bool)
+	for end := to; end.After(from); {
+		var query = url.Values{}
+		query.Set("symbol", symbol)
+		query.Set("type", kind)
+		query.Set("startAt", strconv.FormatInt(from.Unix(), 10))
+		query.Set("endAt", strconv.FormatInt(end.Unix(), 10))
+		var request *kucoin.CallRequest
+		request, err = client.NewCallRequest(http.MethodGet, "/api/v1/market/candles", nil, query, nil)
+		if err != nil {
+			return
+		}
+		var buffer *bytes.Buffer
+		buffer, err = client.Send(request)
+		if err != nil {
+			return
+		}
+		var rows [][7]string
+		err = json.NewDecoder(buffer).Decode(&rows)
+		if err != nil {
+			return
+		}
+		var oldest = end
+		for _, row := range rows {
+			var candle Candle
+			candle, err = parseKline(row)
+			if err != nil {
+				return
+			}
+			if candle.Time.Before(oldest) {
+				oldest = candle.Time
+			}
+			if seen[candle.Time.Unix()] || candle.Time.Before(from) || !candle.Time.Before(to) {
+				continue
+			}
+			seen[candle.Time.Unix()] = true
+			candles = append(candles, candle)
+		}
+		if len(rows) < candlesLimit || !oldest.Before(end) {
+			break
+		}
+		end = oldest
+	}
+	sort.Slice(candles, func(i, j int) bool {
+		return candles[i].Time.Before(candles[j].Time)
+	})
+	return
+}
+
+// parseKline reads a row of [time, open, close, high, low, volume, turnover].
+func parseKline(row [7]string) (candle Candle, err error) {
+	var sec int64
+	sec, err = strconv.ParseInt(row[0], 10, 64)
+	if err != nil {
+		return
+	}
+	candle.Time = time.Unix(sec, 0)
+	for i, v := range []*decimal.Decimal{&candle.Open, &candle.Close, &candle.High, &candle.Low, &candle.Volume, &candle.Turnover} {
+		*v, err = decimal.NewFromString(row[i+1])
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,84 @@
+package book
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func trade(t time.Time, price string) Trade {
+	return Trade{Price: decimal.RequireFromString(price), Size: decimal.NewFromInt(1), Time: t.UnixNano()}
+}
+
+func TestCandlesStart(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		t        time.Time
+		want     time.Time
+	}{
+		{interval: time.Minute, t: time.Date(2020, 10, 7, 12, 34, 56, 0, time.UTC), want: time.Date(2020, 10, 7, 12, 34, 0, 0, time.UTC)},
+		{interval: 24 * time.Hour, t: time.Date(2020, 10, 7, 12, 34, 56, 0, time.UTC), want: time.Date(2020, 10, 7, 0, 0, 0, 0, time.UTC)},
+		{interval: MaxInterval, t: time.Date(2020, 10, 7, 12, 34, 56, 0, time.UTC), want: time.Date(2020, 10, 5, 0, 0, 0, 0, time.UTC)},
+		{interval: MaxInterval, t: time.Date(2020, 10, 5, 0, 0, 0, 0, time.UTC), want: time.Date(2020, 10, 5, 0, 0, 0, 0, time.UTC)},
+		{interval: MaxInterval, t: time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC), want: time.Date(1969, 12, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.interval.String()+" "+tt.t.Format(time.RFC3339), func(t *testing.T) {
+			candles, err := NewCandles(tt.interval, 0, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := candles.start(tt.t); !got.Equal(tt.want) {
+				t.Fatalf("start = %s, want %s", got.UTC(), tt.want)
+			}
+			if tt.interval == MaxInterval && candles.start(tt.t).UTC().Weekday() != time.Monday {
+				t.Fatal("weekly bar does not open on Monday")
+			}
+		})
+	}
+}
+
+func TestCandlesRoll(t *testing.T) {
+	var t0 = time.Date(2020, 10, 7, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		size   int
+		gap    time.Duration
+		bars   int
+		closed int
+	}{
+		{name: "next interval", size: 10, gap: time.Minute, bars: 2, closed: 1},
+		{name: "empty bars in a gap", size: 10, gap: 4 * time.Minute, bars: 5, closed: 4},
+		{name: "gap clamped to the retention", size: 10, gap: 1000000 * time.Minute, bars: 10, closed: 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var closed []Candle
+			candles, err := NewCandles(time.Minute, tt.size, func(candle Candle) {
+				closed = append(closed, candle)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			candles.OnTrade(trade(t0, "10"))
+			candles.OnTrade(trade(t0.Add(tt.gap), "11"))
+			var bars = candles.Bars()
+			if len(bars) != tt.bars || len(closed) != tt.closed {
+				t.Fatalf("bars = %d, closed = %d, want %d and %d", len(bars), len(closed), tt.bars, tt.closed)
+			}
+			if !closed[0].Time.Equal(t0) || !closed[0].Close.Equal(decimal.NewFromInt(10)) {
+				t.Fatalf("first closed bar = %+v, want the traded bar at %s", closed[0], t0)
+			}
+			for i := 1; i < len(bars); i++ {
+				if bars[i].Time.Sub(bars[i-1].Time) != time.Minute {
+					t.Fatalf("bars %d and %d are %s apart", i-1, i, bars[i].Time.Sub(bars[i-1].Time))
+				}
+			}
+			var open = bars[len(bars)-1]
+			if !open.Time.Equal(t0.Add(tt.gap)) || !open.Open.Equal(decimal.NewFromInt(11)) || open.Count != 1 {
+				t.Fatalf("open bar = %+v, want the trade at %s", open, t0.Add(tt.gap))
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package book
+
+import (
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	bps = decimal.NewFromInt(10000)
+	two = decimal.NewFromInt(2)
+)
+
+type (
+	Level struct {
+		Price decimal.Decimal `json:"price"`
+		Size  decimal.Decimal `json:"size"`
+	}
+
+	Depth struct {
+		lock sync.Locker
+		walk func(side string, f func(price, size decimal.Decimal) (next bool))
+	}
+)
+
+func opposite(side string) string {
+	if side == Bids {
+		return Asks
+	}
+	return Bids
+}
+
+func (d *Depth) best(side string) (level Level, ok bool) {
+	d.walk(side, func(price, size decimal.Decimal) bool {
+		level = Level{Price: price, Size: size}
+		ok = true
+		return false
+	})
+	return
+}
+
+// Walk calls f with the levels of side, best first, until f returns false. The book is locked for
+// the walk, so f must not call back into it.
+func (d *Depth) Walk(side string, f func(level Level) (next bool)) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.walk(side, func(price, size decimal.Decimal) bool {
+		return f(Level{Price: price, Size: size})
+	})
+}
+
+func (d *Depth) BestBid() (level Level, ok bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.best(Bids)
+}
+
+func (d *Depth) BestAsk() (level Level, ok bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.best(Asks)
+}
+
+func (d *Depth) top() (bid, ask Level, ok bool) {
+	var okBid, okAsk bool
+	bid, okBid = d.best(Bids)
+	ask, okAsk = d.best(Asks)
+	ok = okBid && okAsk
+	return
+}
+
+func (d *Depth) Mid() (mid decimal.Decimal, ok bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	bid, ask, ok := d.top()
+	if ok {
+		mid = bid.Price.Add(ask.Price).Div(two)
+	}
+	return
+}
+
+func (d *Depth) Microprice() (price decimal.Decimal, ok bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	bid, ask, ok := d.top()
+	if !ok {
+		return
+	}
+	var total = bid.Size.Add(ask.Size)
+	if total.IsZero() {
+		ok = false
+		return
+	}
+	price = bid.Price.Mul(ask.Size).Add(ask.Price.Mul(bid.Size)).Div(total)
+	return
+}
+
+func (d *Depth) SpreadBps() (spread decimal.Decimal, ok bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	bid, ask, ok := d.top()
+	if !ok {
+		return
+	}
+	var mid = bid.Price.Add(ask.Price).Div(two)
+	if mid.IsZero() {
+		ok = false
+		return
+	}
+	spread = ask.Price.Sub(bid.Price).Div(mid).Mul(bps)
+	return
+}
+
+// DepthToPrice sums the resting size on side from the best price up to and including price.
+func (d *Depth) DepthToPrice(side string, price decimal.Decimal) (size decimal.Decimal) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.walk(side, func(p, s decimal.Decimal) bool {
+		if (side == Bids && p.LessThan(price)) || (side == Asks && p.GreaterThan(price)) {
+			return false
+		}
+		size = size.Add(s)
+		return true
+	})
+	return
+}
+
+// DepthToNotional returns the resting size on side needed to reach notional and the last price touched.
+func (d *Depth) DepthToNotional(side string, notional decimal.Decimal) (size, price decimal.Decimal, ok bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var total decimal.Decimal
+	d.walk(side, func(p, s decimal.Decimal) bool {
+		price = p
+		var value = p.Mul(s)
+		if total.Add(value).GreaterThanOrEqual(notional) {
+			size = size.Add(notional.Sub(total).Div(p))
+			ok = true
+			return false
+		}
+		total = total.Add(value)
+		size = size.Add(s)
+		return true
+	})
+	return
+}
+
+// PriceForSize returns the price on side at which the cumulative resting size reaches size.
+func (d *Depth) PriceForSize(side string, size decimal.Decimal) (price decimal.Decimal, ok bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var total decimal.Decimal
+	d.walk(side, func(p, s decimal.Decimal) bool {
+		total = total.Add(s)
+		if total.GreaterThanOrEqual(size) {
+			price = p
+			ok = true
+			return false
+		}
+		return true
+	})
+	return
+}
+
+// VWAP returns the average fill price of a market order of size sent by taker, which consumes the opposite side.
+func (d *Depth) VWAP(taker string, size decimal.Decimal) (price, filled decimal.Decimal, ok bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	price, filled, ok = d.vwap(taker, size)
+	return
+}
+
+func (d *Depth) vwap(taker string, size decimal.Decimal) (price, filled decimal.Decimal, ok bool) {
+	var notional decimal.Decimal
+	d.walk(opposite(taker), func(p, s decimal.Decimal) bool {
+		var take = decimal.Min(s, size.Sub(filled))
+		notional = notional.Add(take.Mul(p))
+		filled = filled.Add(take)
+		return filled.LessThan(size)
+	})
+	if filled.IsZero() {
+		return
+	}
+	price = notional.Div(filled)
+	ok = filled.Equal(size)
+	return
+}
+
+// Slippage returns the cost in bps of a market order of size sent by taker against the best opposite price.
+func (d *Depth) Slippage(taker string, size decimal.Decimal) (slippage decimal.Decimal, ok bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	best, found := d.best(opposite(taker))
+	if !found || best.Price.IsZero() {
+		return
+	}
+	price, _, ok := d.vwap(taker, size)
+	if !ok {
+		return
+	}
+	slippage = price.Sub(best.Price).Div(best.Price).Mul(bps)
+	if taker == Asks {
+		slippage = slippage.Neg()
+	}
+	return
+}
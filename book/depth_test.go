@@ -0,0 +1,95 @@
+package book
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestDepth(t *testing.T) {
+	var book, bids = NewL2(), NewL2()
+	for _, level := range [][3]string{
+		{Bids, "99", "3"}, {Bids, "98", "1"}, {Bids, "97", "2"},
+		{Asks, "101", "1"}, {Asks, "102", "1"}, {Asks, "104", "2"},
+	} {
+		if err := book.Set(level[0], level[1], level[2]); err != nil {
+			t.Fatal(err)
+		}
+		if level[0] == Bids {
+			if err := bids.Set(level[0], level[1], level[2]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var d = decimal.RequireFromString
+	var vwap = func(book *L2, taker, size string) func() (string, bool) {
+		return func() (string, bool) {
+			price, filled, ok := book.VWAP(taker, d(size))
+			return price.String() + " " + filled.String(), ok
+		}
+	}
+	var slippage = func(book *L2, taker, size string) func() (string, bool) {
+		return func() (string, bool) {
+			slippage, ok := book.Slippage(taker, d(size))
+			return slippage.Round(2).String(), ok
+		}
+	}
+	var notional = func(book *L2, side, notional string) func() (string, bool) {
+		return func() (string, bool) {
+			size, price, ok := book.DepthToNotional(side, d(notional))
+			return size.String() + " " + price.String(), ok
+		}
+	}
+	var priceForSize = func(book *L2, side, size string) func() (string, bool) {
+		return func() (string, bool) {
+			price, ok := book.PriceForSize(side, d(size))
+			return price.String(), ok
+		}
+	}
+	var single = func(f func() (decimal.Decimal, bool)) func() (string, bool) {
+		return func() (string, bool) {
+			v, ok := f()
+			return v.String(), ok
+		}
+	}
+	tests := []struct {
+		name string
+		f    func() (string, bool)
+		want string
+		ok   bool
+	}{
+		{name: "vwap inside the best level", f: vwap(book, Bids, "0.5"), want: "101 0.5", ok: true},
+		{name: "vwap filling the best level exactly", f: vwap(book, Bids, "1"), want: "101 1", ok: true},
+		{name: "vwap across levels", f: vwap(book, Bids, "2"), want: "101.5 2", ok: true},
+		{name: "vwap selling", f: vwap(book, Asks, "4"), want: "98.75 4", ok: true},
+		{name: "vwap larger than the depth", f: vwap(book, Bids, "6"), want: "102.75 4"},
+		{name: "vwap on an empty side", f: vwap(bids, Bids, "1"), want: "0 0"},
+		{name: "slippage buying", f: slippage(book, Bids, "2"), want: "49.5", ok: true},
+		{name: "slippage selling", f: slippage(book, Asks, "4"), want: "25.25", ok: true},
+		{name: "slippage at the best level", f: slippage(book, Bids, "1"), want: "0", ok: true},
+		{name: "slippage larger than the depth", f: slippage(book, Bids, "6"), want: "0"},
+		{name: "slippage on an empty side", f: slippage(bids, Bids, "1"), want: "0"},
+		{name: "microprice", f: single(book.Microprice), want: "100.5", ok: true},
+		{name: "microprice on an empty side", f: single(bids.Microprice), want: "0"},
+		{name: "spread", f: single(book.SpreadBps), want: "200", ok: true},
+		{name: "spread on an empty side", f: single(bids.SpreadBps), want: "0"},
+		{name: "notional at a level boundary", f: notional(book, Asks, "101"), want: "1 101", ok: true},
+		{name: "notional inside a level", f: notional(book, Asks, "152"), want: "1.5 102", ok: true},
+		{name: "notional of bids", f: notional(book, Bids, "297"), want: "3 99", ok: true},
+		{name: "notional larger than the depth", f: notional(book, Asks, "1000"), want: "4 104"},
+		{name: "notional on an empty side", f: notional(bids, Asks, "1"), want: "0 0"},
+		{name: "size at a level boundary", f: priceForSize(book, Asks, "1"), want: "101", ok: true},
+		{name: "size inside a level", f: priceForSize(book, Asks, "1.5"), want: "102", ok: true},
+		{name: "size of bids", f: priceForSize(book, Bids, "3.5"), want: "98", ok: true},
+		{name: "size larger than the depth", f: priceForSize(book, Asks, "5"), want: "0"},
+		{name: "size on an empty side", f: priceForSize(bids, Asks, "1"), want: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.f()
+			if got != tt.want || ok != tt.ok {
+				t.Fatalf("got %s, %v, want %s, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
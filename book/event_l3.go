@@ -0,0 +1,256 @@
+package book
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	TypeReceived = "received"
+	TypeOpen     = "open"
+	TypeDone     = "done"
+	TypeMatch    = "match"
+	TypeChange   = "change"
+
+	ReasonFilled   = "filled"
+	ReasonCanceled = "canceled"
+)
+
+var (
+	ErrSequence     = errors.New("book sequence mismatch")
+	ErrUnknownOrder = errors.New("book unknown order")
+	ErrUnknownEvent = errors.New("book unknown event")
+)
+
+type (
+	EventL3 interface {
+		Update
+		Type() string
+		header() *HeaderL3
+	}
+
+	HeaderL3 struct {
+		Sequence Sequence `json:"sequence"`
+		Symbol   string   `json:"symbol"`
+		Time     string   `json:"time"`
+	}
+
+	ReceivedL3 struct {
+		HeaderL3
+		Side      string `json:"side"`
+		OrderId   string `json:"orderId"`
+		Price     string `json:"price"`
+		Size      string `json:"size"`
+		ClientOid string `json:"clientOid"`
+		OrderType string `json:"orderType"`
+	}
+
+	OpenL3 struct {
+		HeaderL3
+		Side    string `json:"side"`
+		OrderId string `json:"orderId"`
+		Price   string `json:"price"`
+		Size    string `json:"size"`
+	}
+
+	DoneL3 struct {
+		HeaderL3
+		Side    string `json:"side"`
+		OrderId string `json:"orderId"`
+		Price   string `json:"price"`
+		Size    string `json:"size"`
+		Reason  string `json:"reason"`
+	}
+
+	MatchL3 struct {
+		HeaderL3
+		Side         string `json:"side"`
+		Price        string `json:"price"`
+		Size         string `json:"size"`
+		TradeId      string `json:"tradeId"`
+		TakerOrderId string `json:"takerOrderId"`
+		MakerOrderId string `json:"makerOrderId"`
+	}
+
+	ChangeL3 struct {
+		HeaderL3
+		Side    string `json:"side"`
+		OrderId string `json:"orderId"`
+		Price   string `json:"price"`
+		NewSize string `json:"newSize"`
+		OldSize string `json:"oldSize"`
+	}
+)
+
+func (h *HeaderL3) Range() (start, end Sequence) {
+	return h.Sequence, h.Sequence
+}
+
+func (h *HeaderL3) header() *HeaderL3 {
+	return h
+}
+
+func (event *ReceivedL3) Type() string { return TypeReceived }
+
+func (event *OpenL3) Type() string { return TypeOpen }
+
+func (event *DoneL3) Type() string { return TypeDone }
+
+func (event *MatchL3) Type() string { return TypeMatch }
+
+func (event *ChangeL3) Type() string { return TypeChange }
+
+func DecodeL3(data []byte) (event EventL3, err error) {
+	var kind struct {
+		Type string `json:"type"`
+	}
+	err = json.Unmarshal(data, &kind)
+	if err != nil {
+		return
+	}
+	switch kind.Type {
+	case TypeReceived:
+		event = new(ReceivedL3)
+	case TypeOpen:
+		event = new(OpenL3)
+	case TypeDone:
+		event = new(DoneL3)
+	case TypeMatch:
+		event = new(MatchL3)
+	case TypeChange:
+		event = new(ChangeL3)
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnknownEvent, kind.Type)
+		return
+	}
+	err = json.Unmarshal(data, event)
+	return
+}
+
+// Apply applies event to the book. An event that fails, other than by a guard rejecting it, leaves
+// the sequence where it was; a done of an order neither resting, pending nor filled by a match, a
+// change of an order neither resting nor pending and a match of an order not resting fail with
+// ErrUnknownOrder.
+func (book *L3) Apply(event EventL3) (err error) {
+	book.m.Lock()
+	defer book.unlock()
+	var sequence = event.header().Sequence
+	if book.Sequence != 0 && sequence != book.Sequence+1 {
+		err = fmt.Errorf("%w: [have:%d, got:%d]", ErrSequence, book.Sequence, sequence)
+		return
+	}
+	// guards record violations at the sequence of the event.
+	var previous = book.Sequence
+	book.Sequence = sequence
+	var found = true
+	var orderId string
+	switch e := event.(type) {
+	case *ReceivedL3:
+		book.pending[e.OrderId] = e
+	case *OpenL3:
+		delete(book.pending, e.OrderId)
+		err = book.add(e.OrderId, e.Side, e.Price, e.Size, e.Time)
+		if order, ok := book.orders[e.OrderId]; ok && err == nil && order.level != nil {
+			order.level.adds++
+		}
+	case *DoneL3:
+		orderId = e.OrderId
+		_, found = book.pending[e.OrderId]
+		delete(book.pending, e.OrderId)
+		if order, ok := book.orders[e.OrderId]; ok && order.level != nil && e.Reason == ReasonCanceled {
+			order.level.cancels++
+		}
+		if _, ok := book.filled[e.OrderId]; ok {
+			delete(book.filled, e.OrderId)
+			found = true
+		}
+		if book.del(e.OrderId) {
+			found = true
+		}
+	case *MatchL3:
+		orderId = e.MakerOrderId
+		var trade Trade
+		trade, err = book.trade(e)
+		if err != nil {
+			break
+		}
+		var order *OrderL3
+		order, found = book.orders[e.MakerOrderId]
+		if !found {
+			break
+		}
+		if order.level != nil {
+			order.level.fills++
+		}
+		_, err = book.subSize(e.MakerOrderId, e.Size)
+		if err == nil {
+			if _, ok := book.orders[e.MakerOrderId]; !ok {
+				book.filled[e.MakerOrderId] = struct{}{}
+			}
+			book.notifier.trade(trade)
+		}
+	case *ChangeL3:
+		orderId = e.OrderId
+		if received, ok := book.pending[e.OrderId]; ok {
+			received.Size = e.NewSize
+			break
+		}
+		found, err = book.newSize(e.OrderId, e.NewSize)
+	}
+	if err == nil && !found {
+		err = fmt.Errorf("%w: [type:%s, orderId:%s, sequence:%d]", ErrUnknownOrder, event.Type(), orderId, sequence)
+	}
+	if err != nil && !errors.Is(err, ErrRejected) {
+		book.Sequence = previous
+	} else if t, ok := eventTime(event.header().Time); ok {
+		book.Time = t.UnixNano()
+	}
+	return
+}
+
+// trade reads the trade of a match before its maker is filled.
+func (book *L3) trade(e *MatchL3) (trade Trade, err error) {
+	trade = Trade{
+		Own:          book.own[e.MakerOrderId],
+		Sequence:     e.Sequence,
+		TradeId:      e.TradeId,
+		Side:         e.Side,
+		MakerOrderId: e.MakerOrderId,
+		TakerOrderId: e.TakerOrderId,
+	}
+	trade.Price, err = decimal.NewFromString(e.Price)
+	if err != nil {
+		return
+	}
+	trade.Size, err = decimal.NewFromString(e.Size)
+	if err != nil {
+		return
+	}
+	t, ok := eventTime(e.Time)
+	if !ok {
+		err = fmt.Errorf("book invalid match time: %q", e.Time)
+		return
+	}
+	trade.Time = t.UnixNano()
+	return
+}
+
+func (book *L3) Pending(orderId string) (received ReceivedL3, found bool) {
+	book.m.RLock()
+	defer book.m.RUnlock()
+	var v *ReceivedL3
+	v, found = book.pending[orderId]
+	if found {
+		received = *v
+	}
+	return
+}
+
+func (book *L3) PendingLen() int {
+	book.m.RLock()
+	defer book.m.RUnlock()
+	return len(book.pending)
+}
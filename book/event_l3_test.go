@@ -0,0 +1,135 @@
+package book
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// event decodes a level3 event of kind with sequence and the given fields.
+func event(t *testing.T, kind string, sequence Sequence, fields string) EventL3 {
+	t.Helper()
+	e, err := DecodeL3([]byte(fmt.Sprintf(`{"type":%q,"sequence":"%d","symbol":"BTC-USDT","time":"1600000000000",%s}`, kind, sequence, fields)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   func(t *testing.T) []EventL3
+		wantErr  error
+		sequence Sequence
+		orders   int
+	}{
+		{
+			name: "open, match and done",
+			events: func(t *testing.T) []EventL3 {
+				return []EventL3{
+					event(t, TypeReceived, 1, `"orderId":"a","side":"sell","price":"10","size":"2"`),
+					event(t, TypeOpen, 2, `"orderId":"a","side":"sell","price":"10","size":"2"`),
+					event(t, TypeMatch, 3, `"side":"buy","price":"10","size":"1","tradeId":"t","takerOrderId":"b","makerOrderId":"a"`),
+					event(t, TypeDone, 4, `"orderId":"a","side":"sell","reason":"canceled"`),
+				}
+			},
+			sequence: 4,
+		},
+		{
+			name: "done of a maker filled by a match",
+			events: func(t *testing.T) []EventL3 {
+				return []EventL3{
+					event(t, TypeOpen, 1, `"orderId":"a","side":"sell","price":"10","size":"2"`),
+					event(t, TypeMatch, 2, `"side":"buy","price":"10","size":"2","tradeId":"t","takerOrderId":"b","makerOrderId":"a"`),
+					event(t, TypeDone, 3, `"orderId":"a","side":"sell","reason":"filled"`),
+				}
+			},
+			sequence: 3,
+		},
+		{
+			name: "done of a pending taker",
+			events: func(t *testing.T) []EventL3 {
+				return []EventL3{
+					event(t, TypeReceived, 1, `"orderId":"b","side":"buy","price":"10","size":"1"`),
+					event(t, TypeDone, 2, `"orderId":"b","side":"buy","reason":"filled"`),
+				}
+			},
+			sequence: 2,
+		},
+		{
+			name: "sequence gap",
+			events: func(t *testing.T) []EventL3 {
+				return []EventL3{
+					event(t, TypeOpen, 1, `"orderId":"a","side":"sell","price":"10","size":"2"`),
+					event(t, TypeOpen, 3, `"orderId":"b","side":"sell","price":"11","size":"2"`),
+				}
+			},
+			wantErr:  ErrSequence,
+			sequence: 1,
+			orders:   1,
+		},
+		{
+			name: "done of an unknown order",
+			events: func(t *testing.T) []EventL3 {
+				return []EventL3{
+					event(t, TypeOpen, 1, `"orderId":"a","side":"sell","price":"10","size":"2"`),
+					event(t, TypeDone, 2, `"orderId":"x","side":"sell","reason":"canceled"`),
+				}
+			},
+			wantErr:  ErrUnknownOrder,
+			sequence: 1,
+			orders:   1,
+		},
+		{
+			name: "match of an unknown maker",
+			events: func(t *testing.T) []EventL3 {
+				return []EventL3{
+					event(t, TypeOpen, 1, `"orderId":"a","side":"sell","price":"10","size":"2"`),
+					event(t, TypeMatch, 2, `"side":"buy","price":"10","size":"1","tradeId":"t","takerOrderId":"b","makerOrderId":"x"`),
+				}
+			},
+			wantErr:  ErrUnknownOrder,
+			sequence: 1,
+			orders:   1,
+		},
+		{
+			name: "invalid size keeps the sequence",
+			events: func(t *testing.T) []EventL3 {
+				return []EventL3{
+					event(t, TypeOpen, 1, `"orderId":"a","side":"sell","price":"10","size":"2"`),
+					event(t, TypeChange, 2, `"orderId":"a","side":"sell","price":"10","newSize":"x","oldSize":"2"`),
+				}
+			},
+			wantErr:  errors.New("can't convert x to decimal"),
+			sequence: 1,
+			orders:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var book = NewL3()
+			var err error
+			for _, e := range tt.events(t) {
+				err = book.Apply(e)
+				if err != nil {
+					break
+				}
+			}
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("err = %v, want nil", err)
+			case tt.wantErr != nil && err == nil:
+				t.Fatalf("err = nil, want %v", tt.wantErr)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr) && err.Error() != tt.wantErr.Error():
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if book.GetSequence() != tt.sequence {
+				t.Fatalf("sequence = %d, want %d", book.GetSequence(), tt.sequence)
+			}
+			if len(book.orders) != tt.orders {
+				t.Fatalf("orders = %d, want %d", len(book.orders), tt.orders)
+			}
+		})
+	}
+}
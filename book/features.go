@@ -0,0 +1,248 @@
+package book
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type (
+	LevelActivity struct {
+		Price      decimal.Decimal `json:"price"`
+		Size       decimal.Decimal `json:"size"`
+		Orders     int             `json:"orders"`
+		Since      time.Time       `json:"since"`
+		Adds       int64           `json:"adds"`
+		Cancels    int64           `json:"cancels"`
+		Fills      int64           `json:"fills"`
+		AddRate    float64         `json:"addRate"`
+		CancelRate float64         `json:"cancelRate"`
+	}
+
+	Ages struct {
+		Count int           `json:"count"`
+		Min   time.Duration `json:"min"`
+		Max   time.Duration `json:"max"`
+		Mean  time.Duration `json:"mean"`
+		P50   time.Duration `json:"p50"`
+		P90   time.Duration `json:"p90"`
+		P99   time.Duration `json:"p99"`
+	}
+
+	flow struct {
+		time  time.Time
+		value decimal.Decimal
+	}
+
+	// OrderFlow accumulates order flow imbalance from the top of book changes of the book it
+	// observes: bid size arriving at or above the best bid counts positive, ask size arriving at or
+	// below the best ask negative, and size leaving either side the opposite way. Flows are timed
+	// by the book, not the clock, so replayed books give the same windows.
+	OrderFlow struct {
+		m         sync.Mutex
+		retention time.Duration
+		last      *Top
+		total     decimal.Decimal
+		flows     []flow
+	}
+)
+
+// unixTime reads a book or order time, which is in nanoseconds from level3 events and in
+// milliseconds from REST snapshots.
+func unixTime(i int64) time.Time {
+	if i < 1e15 {
+		return time.Unix(0, i*int64(time.Millisecond))
+	}
+	return time.Unix(0, i)
+}
+
+// Imbalance returns (bid - ask) / (bid + ask) of the resting size in the best levels of each side.
+func (d *Depth) Imbalance(levels int) (imbalance decimal.Decimal, ok bool) {
+	if levels <= 0 {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	var sum = func(side string) (size decimal.Decimal) {
+		var n int
+		d.walk(side, func(price, s decimal.Decimal) bool {
+			size = size.Add(s)
+			n++
+			return n < levels
+		})
+		return
+	}
+	var bid, ask = sum(Bids), sum(Asks)
+	var total = bid.Add(ask)
+	if total.IsZero() {
+		return
+	}
+	return bid.Sub(ask).Div(total), true
+}
+
+// QueuePosition returns how many orders and how much size rest ahead of orderId at its level.
+func (book *L3) QueuePosition(orderId string) (position int, ahead decimal.Decimal, found bool) {
+	book.m.RLock()
+	defer book.m.RUnlock()
+	order, found := book.orders[orderId]
+	if !found || order.level == nil {
+		found = false
+		return
+	}
+	position, ahead = order.queue()
+	return
+}
+
+func (order *OrderL3) queue() (position int, ahead decimal.Decimal) {
+	for o := order.level.head; o != order; o = o.next {
+		position++
+		ahead = ahead.Add(o.Size)
+	}
+	return
+}
+
+// Activity returns the order counts of the best levels of side, with add and cancel rates per
+// second since each level was created.
+func (book *L3) Activity(side string, levels int) (activity []LevelActivity) {
+	if levels <= 0 {
+		return
+	}
+	book.m.RLock()
+	defer book.m.RUnlock()
+	var s = book.side(side)
+	if s == nil {
+		return
+	}
+	var now = time.Now()
+	s.walk(func(lv *priceLevel) bool {
+		var a = LevelActivity{
+			Price:   lv.price,
+			Size:    lv.size,
+			Orders:  lv.count,
+			Since:   lv.since,
+			Adds:    lv.adds,
+			Cancels: lv.cancels,
+			Fills:   lv.fills,
+		}
+		if elapsed := now.Sub(lv.since).Seconds(); elapsed > 0 {
+			a.AddRate = float64(lv.adds) / elapsed
+			a.CancelRate = float64(lv.cancels) / elapsed
+		}
+		activity = append(activity, a)
+		return len(activity) < levels
+	})
+	return
+}
+
+// OrderAges summarizes how long the orders resting in the best levels of side have been in the book.
+func (book *L3) OrderAges(side string, levels int, now time.Time) (ages Ages) {
+	if levels <= 0 {
+		return
+	}
+	book.m.RLock()
+	defer book.m.RUnlock()
+	var s = book.side(side)
+	if s == nil {
+		return
+	}
+	var durations []time.Duration
+	var n int
+	s.walk(func(lv *priceLevel) bool {
+		for o := lv.head; o != nil; o = o.next {
+			durations = append(durations, now.Sub(unixTime(o.Time)))
+		}
+		n++
+		return n < levels
+	})
+	if len(durations) == 0 {
+		return
+	}
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	var percentile = func(p int) time.Duration {
+		return durations[(len(durations)-1)*p/100]
+	}
+	ages = Ages{
+		Count: len(durations),
+		Min:   durations[0],
+		Max:   durations[len(durations)-1],
+		Mean:  total / time.Duration(len(durations)),
+		P50:   percentile(50),
+		P90:   percentile(90),
+		P99:   percentile(99),
+	}
+	return
+}
+
+func NewOrderFlow(retention time.Duration) *OrderFlow {
+	return &OrderFlow{retention: retention}
+}
+
+func (of *OrderFlow) OnReset(Sequence) {
+	of.m.Lock()
+	defer of.m.Unlock()
+	of.last = nil
+}
+
+func (of *OrderFlow) OnLevel(LevelUpdate) {}
+
+func (of *OrderFlow) OnTrade(Trade) {}
+
+func (of *OrderFlow) OnTop(top Top) {
+	of.m.Lock()
+	defer of.m.Unlock()
+	var last = of.last
+	of.last = &top
+	if last == nil {
+		return
+	}
+	var e decimal.Decimal
+	if top.Bid.Price.GreaterThanOrEqual(last.Bid.Price) {
+		e = e.Add(top.Bid.Size)
+	}
+	if top.Bid.Price.LessThanOrEqual(last.Bid.Price) {
+		e = e.Sub(last.Bid.Size)
+	}
+	if top.Ask.Price.LessThanOrEqual(last.Ask.Price) {
+		e = e.Sub(top.Ask.Size)
+	}
+	if top.Ask.Price.GreaterThanOrEqual(last.Ask.Price) {
+		e = e.Add(last.Ask.Size)
+	}
+	var now = unixTime(top.Time)
+	of.total = of.total.Add(e)
+	of.flows = append(of.flows, flow{time: now, value: e})
+	var i = sort.Search(len(of.flows), func(i int) bool {
+		return now.Sub(of.flows[i].time) <= of.retention
+	})
+	of.flows = append(of.flows[:0], of.flows[i:]...)
+}
+
+// Total returns the order flow imbalance accumulated since the flow started observing.
+func (of *OrderFlow) Total() decimal.Decimal {
+	of.m.Lock()
+	defer of.m.Unlock()
+	return of.total
+}
+
+// Sum returns the order flow imbalance of the window ending at the latest top change, up to the
+// retention of the flow.
+func (of *OrderFlow) Sum(window time.Duration) (sum decimal.Decimal) {
+	of.m.Lock()
+	defer of.m.Unlock()
+	if len(of.flows) == 0 {
+		return
+	}
+	var since = of.flows[len(of.flows)-1].time.Add(-window)
+	for i := len(of.flows) - 1; i >= 0 && of.flows[i].time.After(since); i-- {
+		sum = sum.Add(of.flows[i].value)
+	}
+	return
+}
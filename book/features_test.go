@@ -0,0 +1,201 @@
+package book
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestImbalance(t *testing.T) {
+	var book, empty = NewL2(), NewL2()
+	for _, level := range [][3]string{{Bids, "10", "3"}, {Bids, "9", "1"}, {Asks, "11", "1"}, {Asks, "12", "3"}} {
+		if err := book.Set(level[0], level[1], level[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name   string
+		book   *L2
+		levels int
+		want   string
+		ok     bool
+	}{
+		{name: "best level", book: book, levels: 1, want: "0.5", ok: true},
+		{name: "two levels", book: book, levels: 2, want: "0", ok: true},
+		{name: "more levels than the book", book: book, levels: 10, want: "0", ok: true},
+		{name: "no levels", book: book, levels: 0, want: "0"},
+		{name: "empty book", book: empty, levels: 1, want: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.book.Imbalance(tt.levels)
+			if got.String() != tt.want || ok != tt.ok {
+				t.Fatalf("imbalance = %s, %v, want %s, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestQueuePosition(t *testing.T) {
+	var book = NewL3()
+	for i, size := range []string{"1", "2", "3"} {
+		if err := book.Add(fmt.Sprint("a", i), Bids, "10", size, "1600000000000"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		id       string
+		position int
+		ahead    string
+		found    bool
+	}{
+		{id: "a0", position: 0, ahead: "0", found: true},
+		{id: "a2", position: 2, ahead: "3", found: true},
+		{id: "unknown", ahead: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			position, ahead, found := book.QueuePosition(tt.id)
+			if position != tt.position || ahead.String() != tt.ahead || found != tt.found {
+				t.Fatalf("queue = %d, %s, %v, want %d, %s, %v", position, ahead, found, tt.position, tt.ahead, tt.found)
+			}
+		})
+	}
+}
+
+func TestActivity(t *testing.T) {
+	var book = NewL3()
+	for i, data := range []string{
+		`"type":"open","orderId":"a","side":"buy","price":"10","size":"1"`,
+		`"type":"open","orderId":"b","side":"buy","price":"10","size":"2"`,
+		`"type":"open","orderId":"c","side":"buy","price":"9","size":"1"`,
+		`"type":"done","orderId":"a","side":"buy","reason":"canceled"`,
+		`"type":"match","side":"sell","price":"10","size":"1","tradeId":"t","takerOrderId":"x","makerOrderId":"b"`,
+	} {
+		e, err := DecodeL3([]byte(fmt.Sprintf(`{"sequence":"%d","symbol":"BTC-USDT","time":"1600000000000",%s}`, i+1, data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = book.Apply(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name   string
+		levels int
+		want   string
+	}{
+		{name: "best level", levels: 1, want: "[10 size:1 orders:1 adds:2 cancels:1 fills:1]"},
+		{name: "two levels", levels: 2, want: "[10 size:1 orders:1 adds:2 cancels:1 fills:1 9 size:1 orders:1 adds:1 cancels:0 fills:0]"},
+		{name: "no levels", levels: 0, want: "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, a := range book.Activity(Bids, tt.levels) {
+				got = append(got, fmt.Sprintf("%s size:%s orders:%d adds:%d cancels:%d fills:%d", a.Price, a.Size, a.Orders, a.Adds, a.Cancels, a.Fills))
+				if a.AddRate <= 0 {
+					t.Fatalf("add rate = %v, want positive", a.AddRate)
+				}
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Fatalf("activity = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderAges(t *testing.T) {
+	var book = NewL3()
+	for _, o := range [][3]string{{"a", "10", "1600000000000"}, {"b", "10", "1600000001000"}, {"c", "9", "1600000002000"}} {
+		if err := book.Add(o[0], Bids, o[1], "1", o[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var now = time.Unix(1600000010, 0)
+	tests := []struct {
+		name   string
+		levels int
+		want   Ages
+	}{
+		{name: "best level", levels: 1, want: Ages{Count: 2, Min: 9 * time.Second, Max: 10 * time.Second, Mean: 9500 * time.Millisecond, P50: 9 * time.Second, P90: 9 * time.Second, P99: 9 * time.Second}},
+		{name: "two levels", levels: 2, want: Ages{Count: 3, Min: 8 * time.Second, Max: 10 * time.Second, Mean: 9 * time.Second, P50: 9 * time.Second, P90: 9 * time.Second, P99: 9 * time.Second}},
+		{name: "no levels", levels: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := book.OrderAges(Bids, tt.levels, now); got != tt.want {
+				t.Fatalf("ages = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderFlow(t *testing.T) {
+	var top = func(seconds int64, bid, bidSize, ask, askSize string) Top {
+		return Top{
+			Time: time.Unix(1600000000+seconds, 0).UnixNano(),
+			Bid:  Level{Price: decimal.RequireFromString(bid), Size: decimal.RequireFromString(bidSize)},
+			Ask:  Level{Price: decimal.RequireFromString(ask), Size: decimal.RequireFromString(askSize)},
+		}
+	}
+	var tops = []Top{
+		top(1, "10", "1", "11", "1"),
+		top(2, "10", "3", "11", "1"),     // bid size grows: +2
+		top(3, "10", "3", "10.5", "2"),   // a better ask arrives: -2
+		top(4, "10.2", "1", "10.5", "2"), // a better bid arrives: +1
+	}
+	tests := []struct {
+		name      string
+		retention time.Duration
+		window    time.Duration
+		sum       string
+	}{
+		{name: "whole retention", retention: time.Minute, window: time.Minute, sum: "1"},
+		{name: "window by book time", retention: time.Minute, window: 1500 * time.Millisecond, sum: "-1"},
+		{name: "empty window", retention: time.Minute, window: 0, sum: "0"},
+		{name: "retention drops old flows", retention: time.Second, window: time.Minute, sum: "-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var of = NewOrderFlow(tt.retention)
+			for _, top := range tops {
+				of.OnTop(top)
+			}
+			if got := of.Total(); got.String() != "1" {
+				t.Fatalf("total = %s, want 1", got)
+			}
+			if got := of.Sum(tt.window); got.String() != tt.sum {
+				t.Fatalf("sum = %s, want %s", got, tt.sum)
+			}
+		})
+	}
+}
+
+func TestOrderFlowBookTime(t *testing.T) {
+	var book, of = NewL3(), NewOrderFlow(time.Minute)
+	book.Observe(of)
+	for i, data := range []string{
+		`"time":"1600000000000","type":"open","orderId":"a","side":"buy","price":"10","size":"1"`,
+		`"time":"1600000000000","type":"open","orderId":"b","side":"sell","price":"11","size":"1"`,
+		`"time":"1600000001000","type":"open","orderId":"c","side":"buy","price":"10","size":"2"`,
+		`"time":"1600000005000","type":"open","orderId":"d","side":"sell","price":"11","size":"4"`,
+	} {
+		e, err := DecodeL3([]byte(fmt.Sprintf(`{"sequence":"%d","symbol":"BTC-USDT",%s}`, i+1, data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = book.Apply(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the flows are years before the clock, so only book time puts them in the window.
+	if got := of.Sum(2 * time.Second); got.String() != "-4" {
+		t.Fatalf("sum = %s, want -4", got)
+	}
+	if got := of.Sum(time.Minute); got.String() != "-2" {
+		t.Fatalf("sum = %s, want -2", got)
+	}
+}
@@ -0,0 +1,129 @@
+package book
+
+import (
+	"sync"
+)
+
+// FeedLimit is the number of levels and trades a Feed keeps between drains. Past it the pending
+// changes are dropped and the next drain is a reset.
+var FeedLimit = 10000
+
+type (
+	// Changes are the notifications collected between two drains. Reset means the book must be
+	// read again, either because it was reset or because Dropped notifications were lost.
+	Changes struct {
+		Reset   bool          `json:"reset"`
+		Top     *Top          `json:"top,omitempty"`
+		Levels  []LevelUpdate `json:"levels,omitempty"`
+		Trades  []Trade       `json:"trades,omitempty"`
+		Dropped int           `json:"dropped,omitempty"`
+	}
+
+	levelKey struct {
+		side string
+		tick int64
+	}
+
+	// Feed collects book notifications for a consumer goroutine. C signals that changes are
+	// waiting and Drain takes them. Top is always the latest; with coalesce only the latest
+	// size of each level is kept between drains. Trades are kept in full up to FeedLimit.
+	Feed struct {
+		m        sync.Mutex
+		coalesce bool
+		c        chan struct{}
+		changes  Changes
+		index    map[levelKey]int
+	}
+)
+
+func NewFeed(coalesce bool) *Feed {
+	return &Feed{
+		coalesce: coalesce,
+		c:        make(chan struct{}, 1),
+		index:    make(map[levelKey]int),
+	}
+}
+
+func (feed *Feed) C() <-chan struct{} {
+	return feed.c
+}
+
+func (feed *Feed) Drain() (changes Changes) {
+	feed.m.Lock()
+	defer feed.m.Unlock()
+	changes, feed.changes = feed.changes, Changes{}
+	feed.index = make(map[levelKey]int)
+	return
+}
+
+func (feed *Feed) signal() {
+	select {
+	case feed.c <- struct{}{}:
+	default:
+	}
+}
+
+func (feed *Feed) OnReset(Sequence) {
+	feed.m.Lock()
+	defer feed.m.Unlock()
+	feed.changes = Changes{Reset: true, Dropped: feed.changes.Dropped}
+	feed.index = make(map[levelKey]int)
+	feed.signal()
+}
+
+func (feed *Feed) OnTop(top Top) {
+	feed.m.Lock()
+	defer feed.m.Unlock()
+	feed.changes.Top = &top
+	feed.signal()
+}
+
+// full drops the pending changes once another one would pass FeedLimit, keeping the top.
+func (feed *Feed) full() bool {
+	var pending = len(feed.changes.Levels) + len(feed.changes.Trades)
+	if pending < FeedLimit {
+		return false
+	}
+	feed.changes = Changes{
+		Reset:   true,
+		Top:     feed.changes.Top,
+		Dropped: feed.changes.Dropped + pending + 1,
+	}
+	feed.index = make(map[levelKey]int)
+	return true
+}
+
+func (feed *Feed) OnLevel(update LevelUpdate) {
+	feed.m.Lock()
+	defer feed.m.Unlock()
+	defer feed.signal()
+	var key levelKey
+	var coalesce = feed.coalesce
+	if coalesce {
+		t, err := priceTick(update.Price)
+		key, coalesce = levelKey{side: update.Side, tick: t}, err == nil
+	}
+	if coalesce {
+		if i, ok := feed.index[key]; ok {
+			feed.changes.Levels[i] = update
+			return
+		}
+	}
+	if feed.full() {
+		return
+	}
+	if coalesce {
+		feed.index[key] = len(feed.changes.Levels)
+	}
+	feed.changes.Levels = append(feed.changes.Levels, update)
+}
+
+func (feed *Feed) OnTrade(trade Trade) {
+	feed.m.Lock()
+	defer feed.m.Unlock()
+	defer feed.signal()
+	if feed.full() {
+		return
+	}
+	feed.changes.Trades = append(feed.changes.Trades, trade)
+}
@@ -0,0 +1,214 @@
+package book
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	PolicyLog Policy = iota
+	PolicyReject
+	PolicyResync
+)
+
+const (
+	ViolationCrossed   = "crossed"
+	ViolationLocked    = "locked"
+	ViolationNegative  = "negative"
+	ViolationDuplicate = "duplicate"
+)
+
+var (
+	ErrViolation = errors.New("book guard violation")
+	ErrRejected  = fmt.Errorf("%w: rejected", ErrViolation)
+)
+
+type (
+	// Policy decides what a guarded book does with a mutation that breaks its invariants. Log keeps
+	// the mutation, Reject skips it and returns ErrRejected, which a Sync records without resyncing,
+	// and Resync skips it and returns ErrViolation, which makes a Sync fetch a new book.
+	Policy int32
+
+	Violation struct {
+		Kind     string          `json:"kind"`
+		Sequence Sequence        `json:"sequence"`
+		Side     string          `json:"side"`
+		Id       string          `json:"id,omitempty"`
+		Price    decimal.Decimal `json:"price"`
+		Size     decimal.Decimal `json:"size"`
+	}
+
+	GuardCounters struct {
+		Crossed   int64 `json:"crossed"`
+		Locked    int64 `json:"locked"`
+		Negative  int64 `json:"negative"`
+		Duplicate int64 `json:"duplicate"`
+		Rejected  int64 `json:"rejected"`
+		Resyncs   int64 `json:"resyncs"`
+	}
+
+	// Guard checks the mutations of the books it is set on. One guard may be shared by several
+	// books to count their violations together.
+	Guard struct {
+		m        sync.RWMutex
+		policy   Policy
+		log      func(violation Violation)
+		counters GuardCounters
+	}
+)
+
+func (policy Policy) String() string {
+	switch policy {
+	case PolicyLog:
+		return "log"
+	case PolicyReject:
+		return "reject"
+	case PolicyResync:
+		return "resync"
+	default:
+		return "unknown"
+	}
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("[kind:%s, sequence:%d, side:%s, id:%s, price:%s, size:%s]", v.Kind, v.Sequence, v.Side, v.Id, v.Price, v.Size)
+}
+
+// NewGuard returns a guard applying policy. Violations are written to the standard logger unless
+// SetLog replaces it.
+func NewGuard(policy Policy) *Guard {
+	return &Guard{
+		policy: policy,
+		log: func(violation Violation) {
+			log.Println(ErrViolation, violation)
+		},
+	}
+}
+
+// SetLog replaces the log of violations, nil for none. It may be called while guarded books are
+// being updated.
+func (guard *Guard) SetLog(f func(violation Violation)) {
+	guard.m.Lock()
+	defer guard.m.Unlock()
+	guard.log = f
+}
+
+func (guard *Guard) Policy() Policy {
+	return guard.policy
+}
+
+func (guard *Guard) Counters() GuardCounters {
+	return GuardCounters{
+		Crossed:   atomic.LoadInt64(&guard.counters.Crossed),
+		Locked:    atomic.LoadInt64(&guard.counters.Locked),
+		Negative:  atomic.LoadInt64(&guard.counters.Negative),
+		Duplicate: atomic.LoadInt64(&guard.counters.Duplicate),
+		Rejected:  atomic.LoadInt64(&guard.counters.Rejected),
+		Resyncs:   atomic.LoadInt64(&guard.counters.Resyncs),
+	}
+}
+
+func (guard *Guard) violate(violation Violation) (err error) {
+	switch violation.Kind {
+	case ViolationCrossed:
+		atomic.AddInt64(&guard.counters.Crossed, 1)
+	case ViolationLocked:
+		atomic.AddInt64(&guard.counters.Locked, 1)
+	case ViolationNegative:
+		atomic.AddInt64(&guard.counters.Negative, 1)
+	case ViolationDuplicate:
+		atomic.AddInt64(&guard.counters.Duplicate, 1)
+	}
+	guard.m.RLock()
+	var log = guard.log
+	guard.m.RUnlock()
+	if log != nil {
+		log(violation)
+	}
+	switch guard.policy {
+	case PolicyReject:
+		atomic.AddInt64(&guard.counters.Rejected, 1)
+		err = fmt.Errorf("%w: %s", ErrRejected, violation)
+	case PolicyResync:
+		atomic.AddInt64(&guard.counters.Resyncs, 1)
+		err = fmt.Errorf("%w: %s", ErrViolation, violation)
+	}
+	return
+}
+
+// cross checks that resting size at price on side would not cross or lock the opposite best price.
+func (guard *Guard) cross(d *Depth, sequence Sequence, side, id string, price, size decimal.Decimal) (err error) {
+	best, ok := d.best(opposite(side))
+	if !ok {
+		return
+	}
+	var cmp = price.Cmp(best.Price)
+	if side == Asks {
+		cmp = -cmp
+	}
+	var kind string
+	switch {
+	case cmp > 0:
+		kind = ViolationCrossed
+	case cmp == 0:
+		kind = ViolationLocked
+	default:
+		return
+	}
+	return guard.violate(Violation{Kind: kind, Sequence: sequence, Side: side, Id: id, Price: price, Size: size})
+}
+
+func (guard *Guard) negative(sequence Sequence, side, id string, price, size decimal.Decimal) (err error) {
+	if size.Sign() >= 0 {
+		return
+	}
+	return guard.violate(Violation{Kind: ViolationNegative, Sequence: sequence, Side: side, Id: id, Price: price, Size: size})
+}
+
+func (book *L2) SetGuard(guard *Guard) {
+	book.m.Lock()
+	defer book.m.Unlock()
+	book.guard = guard
+}
+
+func (book *L3) SetGuard(guard *Guard) {
+	book.m.Lock()
+	defer book.m.Unlock()
+	book.guard = guard
+}
+
+// check guards order before it is put in the book: its id must not rest already and its price must
+// not reach the opposite side.
+func (book *L3) check(order *OrderL3) (err error) {
+	if book.guard == nil {
+		return
+	}
+	if _, found := book.orders[order.Id]; found {
+		err = book.guard.violate(Violation{Kind: ViolationDuplicate, Sequence: book.Sequence, Side: order.Side, Id: order.Id, Price: order.Price, Size: order.Size})
+		if err != nil {
+			return
+		}
+	}
+	err = book.guard.negative(book.Sequence, order.Side, order.Id, order.Price, order.Size)
+	if err != nil {
+		return
+	}
+	return book.guard.cross(&book.Depth, book.Sequence, order.Side, order.Id, order.Price, order.Size)
+}
+
+// check guards a level before it is set; removing a level never violates.
+func (book *L2) check(side string, price, size decimal.Decimal) (err error) {
+	if book.guard == nil || size.IsZero() {
+		return
+	}
+	err = book.guard.negative(book.Sequence, side, "", price, size)
+	if err != nil {
+		return
+	}
+	return book.guard.cross(&book.Depth, book.Sequence, side, "", price, size)
+}
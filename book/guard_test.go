@@ -0,0 +1,134 @@
+package book
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestGuardL3(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    Policy
+		id, side  string
+		price     string
+		size      string
+		wantErr   error
+		violation string
+		resting   int
+	}{
+		{name: "valid", policy: PolicyReject, id: "c", side: Bids, price: "10.5", size: "1", resting: 3},
+		{name: "crossed rejected", policy: PolicyReject, id: "c", side: Bids, price: "12", size: "1", wantErr: ErrRejected, violation: ViolationCrossed, resting: 2},
+		{name: "locked logged", policy: PolicyLog, id: "c", side: Asks, price: "10", size: "1", violation: ViolationLocked, resting: 3},
+		{name: "negative resyncs", policy: PolicyResync, id: "c", side: Bids, price: "9", size: "-1", wantErr: ErrViolation, violation: ViolationNegative, resting: 2},
+		{name: "duplicate rejected", policy: PolicyReject, id: "a", side: Bids, price: "9", size: "1", wantErr: ErrRejected, violation: ViolationDuplicate, resting: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var book = NewL3()
+			for _, o := range [][3]string{{"a", Bids, "10"}, {"b", Asks, "11"}} {
+				if err := book.Add(o[0], o[1], o[2], "1", "1600000000000"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var guard = NewGuard(tt.policy)
+			var violations []Violation
+			guard.SetLog(func(violation Violation) { violations = append(violations, violation) })
+			book.SetGuard(guard)
+			err := book.Add(tt.id, tt.side, tt.price, tt.size, "1600000000000")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == ErrViolation && errors.Is(err, ErrRejected) {
+				t.Fatalf("err = %v, want a violation that is not rejected", err)
+			}
+			if tt.violation == "" && len(violations) != 0 || tt.violation != "" && (len(violations) != 1 || violations[0].Kind != tt.violation) {
+				t.Fatalf("violations = %v, want %q", violations, tt.violation)
+			}
+			var resting int
+			for _, side := range []string{Bids, Asks} {
+				book.walk(side, func(price, size decimal.Decimal) bool {
+					resting++
+					return true
+				})
+			}
+			if resting != tt.resting {
+				t.Fatalf("resting levels = %d, want %d", resting, tt.resting)
+			}
+		})
+	}
+}
+
+func TestGuardL2(t *testing.T) {
+	tests := []struct {
+		name    string
+		side    string
+		price   string
+		size    string
+		wantErr error
+		counter func(counters GuardCounters) int64
+	}{
+		{name: "valid", side: Asks, price: "12", size: "1"},
+		{name: "remove never violates", side: Asks, price: "10", size: "0"},
+		{name: "crossed", side: Asks, price: "9", size: "1", wantErr: ErrRejected, counter: func(c GuardCounters) int64 { return c.Crossed }},
+		{name: "locked", side: Bids, price: "11", size: "1", wantErr: ErrRejected, counter: func(c GuardCounters) int64 { return c.Locked }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var book = NewL2()
+			for _, level := range [][2]string{{Bids, "10"}, {Asks, "11"}} {
+				if err := book.Set(level[0], level[1], "1"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var guard = NewGuard(PolicyReject)
+			guard.SetLog(nil)
+			book.SetGuard(guard)
+			err := book.Set(tt.side, tt.price, tt.size)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			var counters = guard.Counters()
+			if tt.counter != nil && (tt.counter(counters) != 1 || counters.Rejected != 1) {
+				t.Fatalf("counters = %+v", counters)
+			}
+			if tt.counter == nil && counters != (GuardCounters{}) {
+				t.Fatalf("counters = %+v, want none", counters)
+			}
+		})
+	}
+}
+
+func TestGuardSetLog(t *testing.T) {
+	var book = NewL2()
+	for _, level := range [][2]string{{Bids, "10"}, {Asks, "11"}} {
+		if err := book.Set(level[0], level[1], "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var guard = NewGuard(PolicyReject)
+	guard.SetLog(nil)
+	book.SetGuard(guard)
+	var logged int64
+	var done = make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			guard.SetLog(func(Violation) { atomic.AddInt64(&logged, 1) })
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if err := book.Set(Asks, "9", "1"); !errors.Is(err, ErrRejected) {
+			t.Fatalf("err = %v, want %v", err, ErrRejected)
+		}
+	}
+	<-done
+	if err := book.Set(Asks, "9", "1"); !errors.Is(err, ErrRejected) {
+		t.Fatalf("err = %v, want %v", err, ErrRejected)
+	}
+	if atomic.LoadInt64(&logged) == 0 {
+		t.Fatal("no violation logged after SetLog")
+	}
+}
@@ -0,0 +1,232 @@
+package book
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PriceScale is the number of decimal places kept when a price is turned into the integer tick
+// that keys its level. Prices finer than this, or whose tick overflows an int64, are rejected with
+// ErrPrice.
+var PriceScale int32 = 10
+
+var ErrPrice = errors.New("book price not representable")
+
+type (
+	priceLevel struct {
+		tick    int64
+		price   decimal.Decimal
+		size    decimal.Decimal
+		count   int
+		head    *OrderL3
+		tail    *OrderL3
+		own     decimal.Decimal
+		since   time.Time
+		adds    int64
+		cancels int64
+		fills   int64
+	}
+
+	// ladder keeps the price levels of one side sorted from worst to best, so changes at the top
+	// of the book touch the end of the slice, and indexed by tick for constant time lookup.
+	ladder struct {
+		name   string
+		levels map[int64]*priceLevel
+		sorted []*priceLevel
+	}
+)
+
+// priceTick returns the integer tick of price at PriceScale.
+func priceTick(price decimal.Decimal) (t int64, err error) {
+	var c = price.Coefficient()
+	var shift = int64(price.Exponent()) + int64(PriceScale)
+	var n = shift
+	if n < 0 {
+		n = -n
+	}
+	var p = new(big.Int).Exp(big.NewInt(10), big.NewInt(n), nil)
+	if shift >= 0 {
+		c.Mul(c, p)
+	} else if _, m := c.QuoRem(c, p, new(big.Int)); m.Sign() != 0 {
+		err = fmt.Errorf("%w: %s finer than %d decimals", ErrPrice, price, PriceScale)
+		return
+	}
+	if !c.IsInt64() {
+		err = fmt.Errorf("%w: %s overflows", ErrPrice, price)
+		return
+	}
+	return c.Int64(), nil
+}
+
+// parseTick returns the tick of price, parsed from its text s without allocating when s is a plain
+// decimal.
+func parseTick(s string, price decimal.Decimal) (t int64, err error) {
+	var i, digits, frac = 0, 0, int32(-1)
+	if len(s) > 0 && s[0] == '-' {
+		i++
+	}
+	for ; i < len(s); i++ {
+		var c = s[i]
+		if c == '.' && frac < 0 {
+			frac = 0
+			continue
+		}
+		if c < '0' || c > '9' {
+			return priceTick(price)
+		}
+		digits++
+		var d = int64(c - '0')
+		if frac >= 0 {
+			if frac == PriceScale {
+				if d != 0 {
+					err = fmt.Errorf("%w: %s finer than %d decimals", ErrPrice, s, PriceScale)
+					return
+				}
+				continue
+			}
+			frac++
+		}
+		if t > (math.MaxInt64-d)/10 {
+			err = fmt.Errorf("%w: %s overflows", ErrPrice, s)
+			return
+		}
+		t = t*10 + d
+	}
+	if digits == 0 {
+		return priceTick(price)
+	}
+	if frac < 0 {
+		frac = 0
+	}
+	for ; frac < PriceScale; frac++ {
+		if t > math.MaxInt64/10 {
+			err = fmt.Errorf("%w: %s overflows", ErrPrice, s)
+			return
+		}
+		t *= 10
+	}
+	if s[0] == '-' {
+		t = -t
+	}
+	return
+}
+
+func newLadder(name string) ladder {
+	return ladder{
+		name:   name,
+		levels: make(map[int64]*priceLevel),
+	}
+}
+
+func (l *ladder) less(a, b int64) bool {
+	if l.name == Bids {
+		return a < b
+	}
+	return a > b
+}
+
+func (l *ladder) search(t int64) int {
+	return sort.Search(len(l.sorted), func(i int) bool {
+		return !l.less(l.sorted[i].tick, t)
+	})
+}
+
+func (l *ladder) get(t int64) (lv *priceLevel, found bool) {
+	lv, found = l.levels[t]
+	return
+}
+
+// level returns the level of tick t, adding it at price when missing.
+func (l *ladder) level(t int64, price decimal.Decimal) (lv *priceLevel) {
+	lv, found := l.levels[t]
+	if found {
+		return
+	}
+	lv = &priceLevel{tick: t, price: price, since: time.Now()}
+	l.levels[t] = lv
+	var i = l.search(t)
+	l.sorted = append(l.sorted, nil)
+	copy(l.sorted[i+1:], l.sorted[i:])
+	l.sorted[i] = lv
+	return
+}
+
+func (l *ladder) remove(lv *priceLevel) {
+	delete(l.levels, lv.tick)
+	var i = l.search(lv.tick)
+	if i == len(l.sorted) || l.sorted[i] != lv {
+		return
+	}
+	copy(l.sorted[i:], l.sorted[i+1:])
+	l.sorted[len(l.sorted)-1] = nil
+	l.sorted = l.sorted[:len(l.sorted)-1]
+}
+
+func (l *ladder) walk(f func(lv *priceLevel) (next bool)) {
+	for i := len(l.sorted) - 1; i >= 0; i-- {
+		if !f(l.sorted[i]) {
+			return
+		}
+	}
+}
+
+// append appends up to n of the best levels to dst, best first.
+func (l *ladder) append(dst []Level, n int) []Level {
+	for i := len(l.sorted) - 1; i >= 0 && n > 0; i, n = i-1, n-1 {
+		dst = append(dst, Level{Price: l.sorted[i].price, Size: l.sorted[i].size})
+	}
+	return dst
+}
+
+// push queues o behind the orders at its level that are not newer than it, which is the tail for
+// orders arriving in sequence.
+func (lv *priceLevel) push(o *OrderL3) {
+	o.level = lv
+	var at = lv.tail
+	for at != nil && at.Time > o.Time {
+		at = at.prev
+	}
+	o.prev = at
+	if at == nil {
+		o.next = lv.head
+		lv.head = o
+	} else {
+		o.next = at.next
+		at.next = o
+	}
+	if o.next == nil {
+		lv.tail = o
+	} else {
+		o.next.prev = o
+	}
+	lv.size = lv.size.Add(o.Size)
+	lv.count++
+	if o.own {
+		lv.own = lv.own.Add(o.Size)
+	}
+}
+
+func (lv *priceLevel) remove(o *OrderL3) {
+	if o.prev == nil {
+		lv.head = o.next
+	} else {
+		o.prev.next = o.next
+	}
+	if o.next == nil {
+		lv.tail = o.prev
+	} else {
+		o.next.prev = o.prev
+	}
+	o.prev, o.next, o.level = nil, nil, nil
+	lv.size = lv.size.Sub(o.Size)
+	lv.count--
+	if o.own {
+		lv.own = lv.own.Sub(o.Size)
+	}
+}
@@ -0,0 +1,266 @@
+package book
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/bzeron/mk/kucoin"
+)
+
+func TestParseTick(t *testing.T) {
+	tests := []struct {
+		price   string
+		want    int64
+		wantErr error
+	}{
+		{price: "10.5", want: 105000000000},
+		{price: "10000", want: 100000000000000},
+		{price: "0.0000000001", want: 1},
+		{price: "1.000000000000", want: 10000000000},
+		{price: "-2.5", want: -25000000000},
+		{price: "1e-3", want: 10000000},
+		{price: "922337203.6854775807", want: 9223372036854775807},
+		{price: "0.00000000001", wantErr: ErrPrice},
+		{price: "1.00000000001", wantErr: ErrPrice},
+		{price: "922337204", wantErr: ErrPrice},
+		{price: "1e9", wantErr: ErrPrice},
+	}
+	for _, tt := range tests {
+		t.Run(tt.price, func(t *testing.T) {
+			var price = decimal.RequireFromString(tt.price)
+			for name, f := range map[string]func() (int64, error){
+				"parse":   func() (int64, error) { return parseTick(tt.price, price) },
+				"decimal": func() (int64, error) { return priceTick(price) },
+			} {
+				got, err := f()
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("%s: err = %v, want %v", name, err, tt.wantErr)
+				}
+				if err == nil && got != tt.want {
+					t.Fatalf("%s: tick = %d, want %d", name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseTickAllocs(t *testing.T) {
+	var price = decimal.RequireFromString("10000.25")
+	if n := testing.AllocsPerRun(100, func() { _, _ = parseTick("10000.25", price) }); n != 0 {
+		t.Fatalf("allocs = %v, want 0", n)
+	}
+}
+
+func TestRejectPrice(t *testing.T) {
+	var l3 = NewL3()
+	if err := l3.Add("a", Bids, "0.00000000001", "1", "1600000000000"); !errors.Is(err, ErrPrice) {
+		t.Fatalf("l3 err = %v, want %v", err, ErrPrice)
+	}
+	var l2 = NewL2()
+	if err := l2.Set(Asks, "1000000000", "1"); !errors.Is(err, ErrPrice) {
+		t.Fatalf("l2 err = %v, want %v", err, ErrPrice)
+	}
+	if _, ok := l3.BestBid(); ok {
+		t.Fatal("rejected price rests in the book")
+	}
+}
+
+// level3Stream generates n messages of a level3 stream resting around a mid of 10000 with a cent
+// tick: orders are opened, canceled, filled in part or whole and resized.
+func level3Stream(n int) (messages [][]byte) {
+	type live struct {
+		id, side, price string
+		size            int64
+	}
+	var r = rand.New(rand.NewSource(1))
+	var orders []*live
+	var sequence, now int64 = 0, 1600000000000000000
+	var emit = func(kind, fields string) {
+		sequence++
+		now += int64(r.Intn(1000000))
+		messages = append(messages, []byte(fmt.Sprintf(`{"type":%q,"sequence":"%d","symbol":"BTC-USDT","time":"%d",%s}`, kind, sequence, now, fields)))
+	}
+	var size = func(units int64) string {
+		return decimal.New(units, -4).String()
+	}
+	var done = func(i int, reason string) {
+		var o = orders[i]
+		emit(TypeDone, fmt.Sprintf(`"orderId":%q,"side":%q,"reason":%q`, o.id, o.side, reason))
+		orders[i] = orders[len(orders)-1]
+		orders = orders[:len(orders)-1]
+	}
+	for id := 0; len(messages) < n; id++ {
+		var p = r.Float64()
+		switch {
+		case p < 0.5 || len(orders) < 100:
+			var o = &live{id: fmt.Sprintf("o%d", id), side: Bids, size: 1 + r.Int63n(100000)}
+			var cents = 1000000 - 1 - r.Int63n(50000)
+			if r.Intn(2) == 0 {
+				o.side = Asks
+				cents = 1000000 + r.Int63n(50000)
+			}
+			o.price = decimal.New(cents, -2).String()
+			var fields = fmt.Sprintf(`"orderId":%q,"side":%q,"price":%q,"size":%q`, o.id, o.side, o.price, size(o.size))
+			emit(TypeReceived, fields)
+			emit(TypeOpen, fields)
+			orders = append(orders, o)
+		case p < 0.8:
+			done(r.Intn(len(orders)), ReasonCanceled)
+		case p < 0.95:
+			var i = r.Intn(len(orders))
+			var o = orders[i]
+			var fill = 1 + r.Int63n(o.size)
+			emit(TypeMatch, fmt.Sprintf(`"side":%q,"price":%q,"size":%q,"tradeId":"t%d","takerOrderId":"x%d","makerOrderId":%q`, opposite(o.side), o.price, size(fill), id, id, o.id))
+			o.size -= fill
+			if o.size == 0 {
+				done(i, ReasonFilled)
+			}
+		default:
+			var o = orders[r.Intn(len(orders))]
+			var old = o.size
+			o.size = 1 + r.Int63n(old)
+			emit(TypeChange, fmt.Sprintf(`"orderId":%q,"side":%q,"price":%q,"newSize":%q,"oldSize":%q`, o.id, o.side, o.price, size(o.size), size(old)))
+		}
+	}
+	return
+}
+
+// recordedLevel3 reads the level3 messages of symbol recorded by kucoin.Recorder in dir.
+func recordedLevel3(dir, symbol string) (messages [][]byte, err error) {
+	files, err := kucoin.ReplayFiles(dir, symbol)
+	if err != nil {
+		return
+	}
+	if len(files) == 0 {
+		err = fmt.Errorf("no recording of %s in %s", symbol, dir)
+		return
+	}
+	replay, err := kucoin.NewReplay(kucoin.ReplayFastest, files...)
+	if err != nil {
+		return
+	}
+	err = replay.Subscribe("/market/level3:"+symbol, "", false, false, func(data []byte) (err error) {
+		messages = append(messages, data)
+		return
+	})
+	if err != nil {
+		return
+	}
+	err = replay.Listen()
+	return
+}
+
+// level3Streams returns a generated stream and, when MK_LEVEL3_RECORDING names a directory of
+// recordings, the one of MK_LEVEL3_SYMBOL (BTC-USDT by default) in it.
+func level3Streams(b *testing.B) map[string][][]byte {
+	var streams = map[string][][]byte{"generated": level3Stream(100000)}
+	if dir := os.Getenv("MK_LEVEL3_RECORDING"); dir != "" {
+		var symbol = os.Getenv("MK_LEVEL3_SYMBOL")
+		if symbol == "" {
+			symbol = "BTC-USDT"
+		}
+		messages, err := recordedLevel3(dir, symbol)
+		if err != nil {
+			b.Fatal(err)
+		}
+		streams["recorded"] = messages
+	}
+	return streams
+}
+
+func decodeLevel3(b *testing.B, messages [][]byte) (events []EventL3) {
+	events = make([]EventL3, 0, len(messages))
+	for _, data := range messages {
+		event, err := DecodeL3(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	return
+}
+
+// BenchmarkApplyL3 replays level3 streams onto an empty book. A recording starts in the middle of
+// the book, so events of orders opened before it fail and are skipped.
+func BenchmarkApplyL3(b *testing.B) {
+	for name, messages := range level3Streams(b) {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			var failed int
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				var events = decodeLevel3(b, messages)
+				var l3 = NewL3()
+				b.StartTimer()
+				for _, event := range events {
+					if l3.Apply(event) != nil {
+						failed++
+						_, end := event.Range()
+						l3.SetSequence(end)
+					}
+				}
+			}
+			b.ReportMetric(float64(len(messages)), "events/op")
+			b.ReportMetric(float64(failed)/float64(b.N), "failed/op")
+		})
+	}
+}
+
+func BenchmarkTick(b *testing.B) {
+	const s = "10000.25"
+	var price = decimal.RequireFromString(s)
+	b.Run("parse", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = parseTick(s, price)
+		}
+	})
+	b.Run("decimal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = priceTick(price)
+		}
+	})
+	b.Run("shift", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = price.Shift(PriceScale).IntPart()
+		}
+	})
+}
+
+// BenchmarkLevels compares reading the best levels of a book through AppendLevels and ToL2.
+func BenchmarkLevels(b *testing.B) {
+	var l3 = NewL3()
+	for _, event := range decodeLevel3(b, level3Stream(100000)) {
+		if err := l3.Apply(event); err != nil {
+			b.Fatal(err)
+		}
+	}
+	const levels = 20
+	b.Run("append", func(b *testing.B) {
+		b.ReportAllocs()
+		var asks, bids = make([]Level, 0, levels), make([]Level, 0, levels)
+		for i := 0; i < b.N; i++ {
+			asks, bids = l3.AppendLevels(asks[:0], bids[:0], levels)
+		}
+	})
+	b.Run("l2", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var l2 = l3.ToL2()
+			var n int
+			for _, side := range []string{Asks, Bids} {
+				l2.walk(side, func(price, size decimal.Decimal) bool {
+					n++
+					return n%levels != 0
+				})
+			}
+		}
+	})
+}
@@ -0,0 +1,276 @@
+package book
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bzeron/mk/kucoin"
+)
+
+const TopicL3 = "/market/level3:"
+
+var ErrNotManaged = errors.New("book symbol not managed")
+
+type (
+	Subscriber interface {
+		Subscribe(topic string, private, ack bool, event kucoin.Event) (err error)
+		Unsubscribe(topic string, private, ack bool) (err error)
+	}
+
+	Snapshot func(symbol string, book *L3) (err error)
+
+	Health struct {
+		Symbol   string        `json:"symbol"`
+		State    State         `json:"state"`
+		Sequence Sequence      `json:"sequence"`
+		Events   int64         `json:"events"`
+		Err      error         `json:"-"`
+		Lag      time.Duration `json:"lag"`
+		Idle     time.Duration `json:"idle"`
+	}
+
+	managed struct {
+		symbol  string
+		book    *L3
+		sync    *Sync
+		refs    int
+		ready   chan struct{}
+		err     error
+		events  int64
+		lag     int64
+		applied int64
+	}
+
+	// Manager keeps one synchronized L3 per symbol over a shared Subscriber. Books are started by
+	// the first Acquire of a symbol and stopped by its last Release.
+	Manager struct {
+		m          sync.RWMutex
+		subscriber Subscriber
+		snapshot   Snapshot
+		books      map[string]*managed
+	}
+)
+
+// SnapshotL3 fetches level3 snapshots through client.
+func SnapshotL3(client *kucoin.Client) Snapshot {
+	return func(symbol string, book *L3) (err error) {
+		var query = url.Values{}
+		query.Set("symbol", symbol)
+		var request *kucoin.CallRequest
+		request, err = client.NewCallRequest(http.MethodGet, "/api/v1/market/orderbook/level3", nil, query, nil)
+		if err != nil {
+			return
+		}
+		var buffer *bytes.Buffer
+		buffer, err = client.Send(request)
+		if err != nil {
+			return
+		}
+		err = json.NewDecoder(buffer).Decode(book)
+		return
+	}
+}
+
+func NewManager(subscriber Subscriber, snapshot Snapshot) *Manager {
+	return &Manager{
+		subscriber: subscriber,
+		snapshot:   snapshot,
+		books:      make(map[string]*managed),
+	}
+}
+
+// eventTime reads a KuCoin event time, which is in nanoseconds for level3 and milliseconds elsewhere.
+func eventTime(s string) (t time.Time, ok bool) {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || i <= 0 {
+		return
+	}
+	if i < 1e15 {
+		return time.Unix(0, i*int64(time.Millisecond)), true
+	}
+	return time.Unix(0, i), true
+}
+
+func (manager *Manager) start(symbol string) (mb *managed) {
+	mb = &managed{symbol: symbol, book: NewL3(), ready: make(chan struct{})}
+	mb.sync = NewSyncL3(mb.book, func(book *L3) error {
+		return manager.snapshot(symbol, book)
+	})
+	mb.sync.observe(func(update Update) {
+		var now = time.Now()
+		atomic.AddInt64(&mb.events, 1)
+		atomic.StoreInt64(&mb.applied, now.UnixNano())
+		if event, ok := update.(EventL3); ok {
+			if t, ok := eventTime(event.header().Time); ok {
+				atomic.StoreInt64(&mb.lag, int64(now.Sub(t)))
+			}
+		}
+	})
+	return
+}
+
+// event feeds data to the sync of mb. A message that cannot be decoded is logged and the book
+// resynced rather than returned, so it does not end the connection shared with other symbols.
+func (mb *managed) event(data []byte) (err error) {
+	if e := mb.sync.Event(data); e != nil {
+		log.Println("book", mb.symbol, e)
+		mb.sync.Resync()
+	}
+	return
+}
+
+// Acquire returns the book of symbol, subscribing to its level3 topic if no one holds it yet. The
+// subscription waits for its ack outside the manager lock; concurrent Acquires of the same symbol
+// wait for it and share its result.
+func (manager *Manager) Acquire(symbol string) (book *L3, err error) {
+	manager.m.Lock()
+	mb, found := manager.books[symbol]
+	if !found {
+		mb = manager.start(symbol)
+		manager.books[symbol] = mb
+	}
+	mb.refs++
+	manager.m.Unlock()
+	if found {
+		<-mb.ready
+		if mb.err != nil {
+			return nil, mb.err
+		}
+		return mb.book, nil
+	}
+	err = manager.subscriber.Subscribe(TopicL3+symbol, false, true, mb.event)
+	if err != nil {
+		manager.m.Lock()
+		if manager.books[symbol] == mb {
+			delete(manager.books, symbol)
+		}
+		manager.m.Unlock()
+		mb.sync.Close()
+		mb.err = err
+	}
+	close(mb.ready)
+	if err != nil {
+		return
+	}
+	book = mb.book
+	return
+}
+
+// Release drops a reference taken by Acquire and, when it was the last one, unsubscribes symbol
+// and stops its sync. The unsubscription waits for its ack outside the manager lock.
+func (manager *Manager) Release(symbol string) (err error) {
+	manager.m.Lock()
+	mb, found := manager.books[symbol]
+	if !found {
+		manager.m.Unlock()
+		return ErrNotManaged
+	}
+	mb.refs--
+	if mb.refs > 0 {
+		manager.m.Unlock()
+		return
+	}
+	delete(manager.books, symbol)
+	manager.m.Unlock()
+	mb.sync.Close()
+	err = manager.subscriber.Unsubscribe(TopicL3+symbol, false, true)
+	return
+}
+
+func (manager *Manager) Book(symbol string) (book *L3, found bool) {
+	manager.m.RLock()
+	defer manager.m.RUnlock()
+	mb, found := manager.books[symbol]
+	if found {
+		book = mb.book
+	}
+	return
+}
+
+func (manager *Manager) Sync(symbol string) (syncer *Sync, found bool) {
+	manager.m.RLock()
+	defer manager.m.RUnlock()
+	mb, found := manager.books[symbol]
+	if found {
+		syncer = mb.sync
+	}
+	return
+}
+
+func (manager *Manager) Symbols() (symbols []string) {
+	manager.m.RLock()
+	defer manager.m.RUnlock()
+	symbols = make([]string, 0, len(manager.books))
+	for symbol := range manager.books {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return
+}
+
+func (mb *managed) health() (health Health) {
+	health = Health{
+		Symbol:   mb.symbol,
+		State:    mb.sync.State(),
+		Sequence: mb.book.GetSequence(),
+		Events:   atomic.LoadInt64(&mb.events),
+		Err:      mb.sync.Err(),
+		Lag:      time.Duration(atomic.LoadInt64(&mb.lag)),
+	}
+	if applied := atomic.LoadInt64(&mb.applied); applied != 0 {
+		health.Idle = time.Since(time.Unix(0, applied))
+	}
+	return
+}
+
+func (manager *Manager) Health(symbol string) (health Health, found bool) {
+	manager.m.RLock()
+	defer manager.m.RUnlock()
+	mb, found := manager.books[symbol]
+	if found {
+		health = mb.health()
+	}
+	return
+}
+
+func (manager *Manager) Healths() (healths []Health) {
+	manager.m.RLock()
+	defer manager.m.RUnlock()
+	healths = make([]Health, 0, len(manager.books))
+	for _, mb := range manager.books {
+		healths = append(healths, mb.health())
+	}
+	sort.Slice(healths, func(i, j int) bool {
+		return healths[i].Symbol < healths[j].Symbol
+	})
+	return
+}
+
+// Close unsubscribes every managed symbol and stops its sync, returning the first error. Symbols
+// still being acquired are waited for outside the manager lock.
+func (manager *Manager) Close() (err error) {
+	manager.m.Lock()
+	var books = manager.books
+	manager.books = make(map[string]*managed)
+	manager.m.Unlock()
+	for symbol, mb := range books {
+		<-mb.ready
+		if mb.err != nil {
+			continue
+		}
+		mb.sync.Close()
+		if e := manager.subscriber.Unsubscribe(TopicL3+symbol, false, true); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
@@ -0,0 +1,171 @@
+package book
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bzeron/mk/kucoin"
+)
+
+// testSubscriber acks a subscription once gate is closed, failing it with err. unsubscribe is
+// called before an unsubscription is acked.
+type testSubscriber struct {
+	m           sync.Mutex
+	gate        chan struct{}
+	err         error
+	topics      map[string]int
+	events      map[string]kucoin.Event
+	unsubscribe func()
+}
+
+func newTestSubscriber() *testSubscriber {
+	var gate = make(chan struct{})
+	close(gate)
+	return &testSubscriber{gate: gate, topics: make(map[string]int), events: make(map[string]kucoin.Event)}
+}
+
+func (s *testSubscriber) Subscribe(topic string, private, ack bool, event kucoin.Event) (err error) {
+	<-s.gate
+	if s.err != nil {
+		return s.err
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.topics[topic]++
+	s.events[topic] = event
+	return
+}
+
+func (s *testSubscriber) Unsubscribe(topic string, private, ack bool) (err error) {
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.topics[topic]--
+	return
+}
+
+func (s *testSubscriber) subscribed(topic string) int {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.topics[topic]
+}
+
+func noSnapshot(symbol string, book *L3) error {
+	return errors.New("no snapshot")
+}
+
+func TestManagerAcquire(t *testing.T) {
+	var failed = errors.New("subscribe failed")
+	tests := []struct {
+		name    string
+		err     error
+		symbols int
+	}{
+		{name: "subscribes once", symbols: 1},
+		{name: "shares the failure", err: failed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var subscriber = newTestSubscriber()
+			subscriber.gate = make(chan struct{})
+			subscriber.err = tt.err
+			var manager = NewManager(subscriber, noSnapshot)
+			const acquirers = 3
+			var errs = make(chan error, acquirers)
+			for i := 0; i < acquirers; i++ {
+				go func() {
+					_, err := manager.Acquire("BTC-USDT")
+					errs <- err
+				}()
+			}
+			// the pending subscription must not hold the manager.
+			var read = make(chan struct{})
+			go func() {
+				manager.Symbols()
+				manager.Healths()
+				close(read)
+			}()
+			select {
+			case <-read:
+			case <-time.After(2 * time.Second):
+				t.Fatal("manager locked while subscribing")
+			}
+			close(subscriber.gate)
+			for i := 0; i < acquirers; i++ {
+				if err := <-errs; err != tt.err {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+			}
+			if got := len(manager.Symbols()); got != tt.symbols {
+				t.Fatalf("symbols = %d, want %d", got, tt.symbols)
+			}
+			if got := subscriber.subscribed(TopicL3 + "BTC-USDT"); got != tt.symbols {
+				t.Fatalf("subscriptions = %d, want %d", got, tt.symbols)
+			}
+		})
+	}
+}
+
+func TestManagerRelease(t *testing.T) {
+	tests := []struct {
+		name    string
+		release func(manager *Manager) error
+	}{
+		{name: "release", release: func(manager *Manager) error { return manager.Release("BTC-USDT") }},
+		{name: "close", release: func(manager *Manager) error { return manager.Close() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var subscriber = newTestSubscriber()
+			var manager = NewManager(subscriber, noSnapshot)
+			if _, err := manager.Acquire("BTC-USDT"); err != nil {
+				t.Fatal(err)
+			}
+			syncer, _ := manager.Sync("BTC-USDT")
+			// the unsubscription must not hold the manager.
+			subscriber.unsubscribe = func() { manager.Symbols() }
+			var released = make(chan error, 1)
+			go func() { released <- tt.release(manager) }()
+			select {
+			case err := <-released:
+				if err != nil {
+					t.Fatal(err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("manager locked while unsubscribing")
+			}
+			if syncer.State() != StateClosed {
+				t.Fatalf("state = %s, want %s", syncer.State(), StateClosed)
+			}
+			if got := subscriber.subscribed(TopicL3 + "BTC-USDT"); got != 0 {
+				t.Fatalf("subscriptions = %d, want 0", got)
+			}
+			if _, found := manager.Book("BTC-USDT"); found {
+				t.Fatal("book still managed")
+			}
+		})
+	}
+}
+
+func TestManagerDecodeError(t *testing.T) {
+	var subscriber = newTestSubscriber()
+	var manager = NewManager(subscriber, noSnapshot)
+	defer manager.Close()
+	if _, err := manager.Acquire("BTC-USDT"); err != nil {
+		t.Fatal(err)
+	}
+	subscriber.m.Lock()
+	var event = subscriber.events[TopicL3+"BTC-USDT"]
+	subscriber.m.Unlock()
+	if err := event([]byte("{")); err != nil {
+		t.Fatalf("err = %v, want the connection kept", err)
+	}
+	syncer, _ := manager.Sync("BTC-USDT")
+	if syncer.State() != StateResyncing {
+		t.Fatalf("state = %s, want %s", syncer.State(), StateResyncing)
+	}
+}
@@ -0,0 +1,195 @@
+package book
+
+import (
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+type (
+	Top struct {
+		Sequence Sequence `json:"sequence"`
+		Time     int64    `json:"time"` // nanoseconds, the book time of the change
+		Bid      Level    `json:"bid"`
+		Ask      Level    `json:"ask"`
+	}
+
+	LevelUpdate struct {
+		Sequence Sequence        `json:"sequence"`
+		Side     string          `json:"side"`
+		Price    decimal.Decimal `json:"price"`
+		Size     decimal.Decimal `json:"size"`
+	}
+
+	Trade struct {
+		Sequence     Sequence        `json:"sequence"`
+		TradeId      string          `json:"tradeId"`
+		Side         string          `json:"side"`
+		Price        decimal.Decimal `json:"price"`
+		Size         decimal.Decimal `json:"size"`
+		MakerOrderId string          `json:"makerOrderId"`
+		TakerOrderId string          `json:"takerOrderId"`
+		Time         int64           `json:"time"` // nanoseconds
+		Own          bool            `json:"own"`  // the maker order is tagged own
+	}
+
+	Observer interface {
+		OnReset(sequence Sequence)
+		OnTop(top Top)
+		OnLevel(update LevelUpdate)
+		OnTrade(trade Trade)
+	}
+
+	batch struct {
+		sequence Sequence
+		reset    bool
+		top      *Top
+		levels   []LevelUpdate
+		trades   []Trade
+	}
+
+	notifier struct {
+		m         sync.RWMutex
+		q         sync.Mutex
+		queue     []batch
+		draining  bool
+		observers map[int]Observer
+		next      int
+		top       Top
+		batch     batch
+	}
+)
+
+func (top Top) equal(other Top) bool {
+	return top.Bid.Price.Equal(other.Bid.Price) && top.Bid.Size.Equal(other.Bid.Size) &&
+		top.Ask.Price.Equal(other.Ask.Price) && top.Ask.Size.Equal(other.Ask.Size)
+}
+
+func (n *notifier) Observe(observer Observer) (cancel func()) {
+	n.m.Lock()
+	defer n.m.Unlock()
+	if n.observers == nil {
+		n.observers = make(map[int]Observer)
+	}
+	var id = n.next
+	n.next++
+	n.observers[id] = observer
+	return func() {
+		n.m.Lock()
+		defer n.m.Unlock()
+		delete(n.observers, id)
+	}
+}
+
+func (n *notifier) observed() bool {
+	n.m.RLock()
+	defer n.m.RUnlock()
+	return len(n.observers) > 0
+}
+
+func (n *notifier) reset() {
+	n.batch = batch{reset: true}
+}
+
+func (n *notifier) level(side string, price, size decimal.Decimal) {
+	n.batch.levels = append(n.batch.levels, LevelUpdate{Side: side, Price: price, Size: size})
+}
+
+func (n *notifier) trade(trade Trade) {
+	n.batch.trades = append(n.batch.trades, trade)
+}
+
+func (n *notifier) take(sequence Sequence, t int64, d *Depth) (b batch) {
+	b, n.batch = n.batch, batch{}
+	if !n.observed() {
+		return batch{}
+	}
+	b.sequence = sequence
+	for i := range b.levels {
+		b.levels[i].Sequence = sequence
+	}
+	var top = Top{Sequence: sequence, Time: t}
+	top.Bid, _ = d.best(Bids)
+	top.Ask, _ = d.best(Asks)
+	if b.reset || !top.equal(n.top) {
+		n.top = top
+		b.top = &top
+	}
+	return
+}
+
+func (b *batch) empty() bool {
+	return !b.reset && b.top == nil && len(b.levels) == 0 && len(b.trades) == 0
+}
+
+// commit queues the pending batch while the book is still write locked, so batches queue in the
+// order of the mutations, then unlocks the book and drains the queue unless another writer is
+// already draining it. No lock is held while observers run, so they may read the book; a batch
+// queued while another writer drains is dispatched by that writer.
+func (n *notifier) commit(lock sync.Locker, sequence Sequence, t int64, d *Depth) {
+	var b = n.take(sequence, t, d)
+	if b.empty() {
+		lock.Unlock()
+		return
+	}
+	n.q.Lock()
+	n.queue = append(n.queue, b)
+	var drain = !n.draining
+	n.draining = true
+	n.q.Unlock()
+	lock.Unlock()
+	if drain {
+		n.drain()
+	}
+}
+
+func (n *notifier) drain() {
+	var done bool
+	defer func() {
+		if !done {
+			// an observer panicked; let the next writer drain the rest.
+			n.q.Lock()
+			n.draining = false
+			n.q.Unlock()
+		}
+	}()
+	for {
+		n.q.Lock()
+		if len(n.queue) == 0 {
+			n.queue = nil
+			n.draining = false
+			n.q.Unlock()
+			done = true
+			return
+		}
+		var b = n.queue[0]
+		n.queue = n.queue[1:]
+		n.q.Unlock()
+		n.dispatch(b)
+	}
+}
+
+// dispatch sends b to a copy of the observers taken under the lock, so an observer may cancel
+// itself or observe the book again from its callbacks.
+func (n *notifier) dispatch(b batch) {
+	n.m.RLock()
+	var observers = make([]Observer, 0, len(n.observers))
+	for _, observer := range n.observers {
+		observers = append(observers, observer)
+	}
+	n.m.RUnlock()
+	for _, observer := range observers {
+		if b.reset {
+			observer.OnReset(b.sequence)
+		}
+		for _, update := range b.levels {
+			observer.OnLevel(update)
+		}
+		for _, trade := range b.trades {
+			observer.OnTrade(trade)
+		}
+		if b.top != nil {
+			observer.OnTop(*b.top)
+		}
+	}
+}
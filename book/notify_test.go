@@ -0,0 +1,171 @@
+package book
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// reader reads the book from its callbacks and mirrors the levels it is told about.
+type reader struct {
+	book   *L3
+	m      sync.Mutex
+	levels map[string]decimal.Decimal
+}
+
+func (r *reader) OnReset(sequence Sequence) {}
+
+func (r *reader) OnTop(top Top) {}
+
+func (r *reader) OnTrade(trade Trade) {}
+
+func (r *reader) OnLevel(update LevelUpdate) {
+	// give the other writers time to lock the book before reading it.
+	time.Sleep(10 * time.Microsecond)
+	r.book.BestBid()
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.levels[update.Side+update.Price.String()] = update.Size
+}
+
+func TestNotifierConcurrentWriters(t *testing.T) {
+	const writers, orders = 4, 100
+	var book = NewL3()
+	var r = &reader{book: book, levels: make(map[string]decimal.Decimal)}
+	book.Observe(r)
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < orders; i++ {
+				var id = fmt.Sprintf("%d-%d", w, i)
+				var price = fmt.Sprintf("%d.%d", w+1, i)
+				if err := book.Add(id, "buy", price, "1", "1600000000000"); err != nil {
+					t.Error(err)
+					return
+				}
+				if i%2 == 0 {
+					book.Del(id)
+				}
+			}
+		}(w)
+	}
+	var done = make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writers deadlocked with an observer reading the book")
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	var resting int
+	for key, size := range r.levels {
+		if size.IsZero() {
+			continue
+		}
+		resting++
+		if !size.Equal(decimal.NewFromInt(1)) {
+			t.Fatalf("level %s = %s, want 1", key, size)
+		}
+	}
+	var levels int
+	book.Walk(Bids, func(level Level) bool {
+		levels++
+		return true
+	})
+	if resting != levels {
+		t.Fatalf("observed %d levels, book has %d", resting, levels)
+	}
+}
+
+// canceler cancels its own observation from its first callback.
+type canceler struct {
+	cancel func()
+	calls  int
+}
+
+func (c *canceler) OnReset(sequence Sequence) {}
+
+func (c *canceler) OnTop(top Top) {}
+
+func (c *canceler) OnTrade(trade Trade) {}
+
+func (c *canceler) OnLevel(update LevelUpdate) {
+	c.calls++
+	c.cancel()
+}
+
+func TestNotifierCancelInCallback(t *testing.T) {
+	var book = NewL2()
+	var c = &canceler{}
+	c.cancel = book.Observe(c)
+	var done = make(chan error, 1)
+	go func() {
+		if err := book.Set(Bids, "10", "1"); err != nil {
+			done <- err
+			return
+		}
+		done <- book.Set(Bids, "9", "1")
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancel from a callback deadlocked")
+	}
+	if c.calls != 1 {
+		t.Fatalf("calls = %d, want 1", c.calls)
+	}
+}
+
+func TestFeed(t *testing.T) {
+	defer func(limit int) { FeedLimit = limit }(FeedLimit)
+	FeedLimit = 4
+	var level = func(price, size string) LevelUpdate {
+		return LevelUpdate{Side: Bids, Price: decimal.RequireFromString(price), Size: decimal.RequireFromString(size)}
+	}
+	tests := []struct {
+		name     string
+		coalesce bool
+		levels   []LevelUpdate
+		trades   int
+		want     string
+		reset    bool
+		dropped  int
+	}{
+		{name: "coalesces by tick", coalesce: true, levels: []LevelUpdate{level("1.5", "1"), level("1.50", "2"), level("2", "1")}, want: "[1.5:2 2:1]"},
+		{name: "keeps every update", levels: []LevelUpdate{level("1.5", "1"), level("1.50", "2")}, want: "[1.5:1 1.5:2]"},
+		{name: "drops past the limit", levels: []LevelUpdate{level("1", "1"), level("2", "1"), level("3", "1")}, trades: 2, want: "[]", reset: true, dropped: 5},
+		{name: "keeps updates after a drop", levels: []LevelUpdate{level("1", "1"), level("2", "1"), level("3", "1"), level("4", "1"), level("5", "1"), level("6", "1")}, want: "[6:1]", reset: true, dropped: 5},
+		{name: "coalesced levels do not count", coalesce: true, levels: []LevelUpdate{level("1", "1"), level("1", "2"), level("1", "3"), level("1", "4"), level("1", "5")}, want: "[1:5]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var feed = NewFeed(tt.coalesce)
+			for _, update := range tt.levels {
+				feed.OnLevel(update)
+			}
+			for i := 0; i < tt.trades; i++ {
+				feed.OnTrade(Trade{})
+			}
+			var changes = feed.Drain()
+			var got []string
+			for _, update := range changes.Levels {
+				got = append(got, update.Price.String()+":"+update.Size.String())
+			}
+			if fmt.Sprint(got) != tt.want || changes.Reset != tt.reset || changes.Dropped != tt.dropped {
+				t.Fatalf("levels = %v, reset = %v, dropped = %d, want %s, %v, %d", got, changes.Reset, changes.Dropped, tt.want, tt.reset, tt.dropped)
+			}
+		})
+	}
+}
@@ -12,11 +12,3 @@ type OrderL2 struct {
 func (order *OrderL2) String() string {
 	return order.Price.String() + "\t" + order.Size.String()
 }
-
-func orderL2AsksCmp(a, b interface{}) int {
-	return a.(*OrderL2).Price.Cmp(b.(*OrderL2).Price)
-}
-
-func orderL2BidsCmp(a, b interface{}) int {
-	return -a.(*OrderL2).Price.Cmp(b.(*OrderL2).Price)
-}
@@ -13,6 +13,12 @@ type OrderL3 struct {
 	Price decimal.Decimal
 	Size  decimal.Decimal
 	Time  int64
+
+	tick  int64
+	level *priceLevel
+	prev  *OrderL3
+	next  *OrderL3
+	own   bool
 }
 
 func NewOrder(id, side, price, size, timestamp string) (order *OrderL3, err error) {
@@ -23,6 +29,10 @@ func NewOrder(id, side, price, size, timestamp string) (order *OrderL3, err erro
 	if err != nil {
 		return
 	}
+	order.tick, err = parseTick(price, order.Price)
+	if err != nil {
+		return
+	}
 	order.Size, err = decimal.NewFromString(size)
 	if err != nil {
 		return
@@ -41,45 +51,3 @@ func (order *OrderL3) MarshalJSON() ([]byte, error) {
 func (order *OrderL3) String() string {
 	return order.Id + "\t" + order.Price.String() + "\t" + order.Size.String() + "\t" + strconv.FormatInt(order.Time, 10)
 }
-
-func orderL3AsksCmp(a interface{}, b interface{}) int {
-	m := a.(*OrderL3)
-	n := b.(*OrderL3)
-	if m.Id == n.Id {
-		return 0
-	}
-	switch m.Price.Cmp(n.Price) {
-	case -1:
-		return -1
-	case 0:
-		if m.Time < n.Time {
-			return -1
-		}
-		return 1
-	case 1:
-		return 1
-	default:
-		return 1
-	}
-}
-
-func orderL3BidsCmp(a interface{}, b interface{}) int {
-	m := a.(*OrderL3)
-	n := b.(*OrderL3)
-	if m.Id == n.Id {
-		return 0
-	}
-	switch m.Price.Cmp(n.Price) {
-	case -1:
-		return 1
-	case 0:
-		if m.Time < n.Time {
-			return -1
-		}
-		return 1
-	case 1:
-		return -1
-	default:
-		return 1
-	}
-}
@@ -0,0 +1,99 @@
+package book
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+type OwnOrder struct {
+	OrderId  string          `json:"orderId"`
+	Side     string          `json:"side"`
+	Price    decimal.Decimal `json:"price"`
+	Size     decimal.Decimal `json:"size"`
+	Position int             `json:"position"`
+	Ahead    decimal.Decimal `json:"ahead"`
+}
+
+// Market is the depth of the book without the size of own orders, for depth and impact queries
+// that must not count our own liquidity.
+func (book *L3) Market() *Depth {
+	return &book.market
+}
+
+func (book *L3) walkMarket(side string, f func(price, size decimal.Decimal) (next bool)) {
+	var s = book.side(side)
+	if s == nil {
+		return
+	}
+	s.walk(func(lv *priceLevel) bool {
+		var size = lv.size.Sub(lv.own)
+		if size.Sign() <= 0 {
+			return true
+		}
+		return f(lv.price, size)
+	})
+}
+
+// Own tags orderIds as ours. Ids may be tagged before the book sees them, e.g. right after the
+// order was placed; a tag is dropped when its order leaves the book.
+func (book *L3) Own(orderIds ...string) {
+	book.m.Lock()
+	defer book.m.Unlock()
+	for _, id := range orderIds {
+		book.own[id] = true
+		book.tag(id)
+	}
+}
+
+func (book *L3) Disown(orderIds ...string) {
+	book.m.Lock()
+	defer book.m.Unlock()
+	for _, id := range orderIds {
+		delete(book.own, id)
+		order, found := book.orders[id]
+		if !found || !order.own {
+			continue
+		}
+		order.own = false
+		if order.level != nil {
+			order.level.own = order.level.own.Sub(order.Size)
+		}
+	}
+}
+
+func (book *L3) tag(id string) {
+	order, found := book.orders[id]
+	if !found || order.own {
+		return
+	}
+	order.own = true
+	if order.level != nil {
+		order.level.own = order.level.own.Add(order.Size)
+	}
+}
+
+func (book *L3) IsOwn(orderId string) bool {
+	book.m.RLock()
+	defer book.m.RUnlock()
+	return book.own[orderId]
+}
+
+// OwnOrders returns our orders resting in the book with their queue position.
+func (book *L3) OwnOrders() (orders []OwnOrder) {
+	book.m.RLock()
+	defer book.m.RUnlock()
+	for id := range book.own {
+		order, found := book.orders[id]
+		if !found || order.level == nil {
+			continue
+		}
+		var own = OwnOrder{OrderId: id, Side: order.Side, Price: order.Price, Size: order.Size}
+		own.Position, own.Ahead = order.queue()
+		orders = append(orders, own)
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].OrderId < orders[j].OrderId
+	})
+	return
+}
@@ -0,0 +1,82 @@
+package book
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestOwn(t *testing.T) {
+	tests := []struct {
+		name   string
+		apply  func(t *testing.T, book *L3)
+		own    bool
+		orders int
+		market string
+	}{
+		{
+			name:   "tagged before added",
+			apply:  func(t *testing.T, book *L3) { add(t, book, "a", "1") },
+			own:    true,
+			orders: 1,
+			market: "1",
+		},
+		{
+			name: "duplicate add keeps the tag",
+			apply: func(t *testing.T, book *L3) {
+				add(t, book, "a", "1")
+				add(t, book, "a", "1.5")
+			},
+			own:    true,
+			orders: 1,
+			market: "1",
+		},
+		{
+			name: "disowned",
+			apply: func(t *testing.T, book *L3) {
+				add(t, book, "a", "1")
+				book.Disown("a")
+			},
+			orders: 0,
+			market: "2",
+		},
+		{
+			name: "dropped when the order leaves",
+			apply: func(t *testing.T, book *L3) {
+				add(t, book, "a", "1")
+				book.Del("a")
+			},
+			orders: 0,
+			market: "1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var book = NewL3()
+			add(t, book, "b", "1")
+			book.Own("a")
+			tt.apply(t, book)
+			if book.IsOwn("a") != tt.own {
+				t.Fatalf("own = %v, want %v", book.IsOwn("a"), tt.own)
+			}
+			if got := len(book.OwnOrders()); got != tt.orders {
+				t.Fatalf("own orders = %d, want %d", got, tt.orders)
+			}
+			var market decimal.Decimal
+			book.walkMarket(Bids, func(price, size decimal.Decimal) bool {
+				market = market.Add(size)
+				return true
+			})
+			if !market.Equal(decimal.RequireFromString(tt.market)) {
+				t.Fatalf("market = %s, want %s", market, tt.market)
+			}
+		})
+	}
+}
+
+func add(t *testing.T, book *L3, id, size string) {
+	t.Helper()
+	if err := book.Add(id, Bids, "10", size, "1600000000000"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -8,10 +8,12 @@ import (
 type Sequence int64
 
 func (s *Sequence) UnmarshalJSON(b []byte) (err error) {
-	var x string
-	err = json.Unmarshal(b, &x)
-	if err != nil {
-		return
+	var x = string(b)
+	if len(b) > 0 && b[0] == '"' {
+		err = json.Unmarshal(b, &x)
+		if err != nil {
+			return
+		}
 	}
 	var i int64
 	i, err = strconv.ParseInt(x, 10, 64)
@@ -0,0 +1,358 @@
+package book
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	magicL2 = "KBL2"
+	magicL3 = "KBL3"
+
+	snapshotVersion = 1
+)
+
+var ErrSnapshot = errors.New("book invalid snapshot")
+
+type (
+	snapshotL2 struct {
+		Sequence Sequence `json:"sequence"`
+		Time     int64    `json:"time"`
+		Bids     *sideL2  `json:"bids"`
+		Asks     *sideL2  `json:"asks"`
+	}
+
+	snapshotL3 struct {
+		Sequence Sequence      `json:"sequence"`
+		Time     int64         `json:"time"`
+		Bids     *sideL3       `json:"bids"`
+		Asks     *sideL3       `json:"asks"`
+		Pending  []*ReceivedL3 `json:"pending,omitempty"`
+	}
+
+	encoder struct {
+		w   *bytes.Buffer
+		buf [binary.MaxVarintLen64]byte
+	}
+
+	decoder struct {
+		r   *bytes.Reader
+		err error
+	}
+)
+
+func (side *sideL2) MarshalJSON() ([]byte, error) {
+	var s = make([][2]string, 0, len(side.sorted))
+	side.walk(func(lv *priceLevel) bool {
+		s = append(s, [2]string{lv.price.String(), lv.size.String()})
+		return true
+	})
+	return json.Marshal(s)
+}
+
+func (side *sideL3) MarshalJSON() ([]byte, error) {
+	var s = make([]*OrderL3, 0, len(side.orders))
+	side.walk(func(lv *priceLevel) bool {
+		for o := lv.head; o != nil; o = o.next {
+			s = append(s, o)
+		}
+		return true
+	})
+	return json.Marshal(s)
+}
+
+func (book *L2) MarshalJSON() ([]byte, error) {
+	book.m.RLock()
+	defer book.m.RUnlock()
+	return json.Marshal(snapshotL2{
+		Sequence: book.Sequence,
+		Time:     book.Time,
+		Bids:     book.Bids,
+		Asks:     book.Asks,
+	})
+}
+
+// UnmarshalJSON restores a snapshot written by MarshalJSON or returned by the level2 REST endpoint,
+// replacing the whole book at once.
+func (book *L2) UnmarshalJSON(b []byte) (err error) {
+	var fresh = NewL2()
+	var s = snapshotL2{Bids: fresh.Bids, Asks: fresh.Asks}
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return
+	}
+	fresh.Sequence, fresh.Time = s.Sequence, s.Time
+	book.replace(fresh)
+	return
+}
+
+func (book *L3) MarshalJSON() ([]byte, error) {
+	book.m.RLock()
+	defer book.m.RUnlock()
+	var pending = make([]*ReceivedL3, 0, len(book.pending))
+	for _, received := range book.pending {
+		pending = append(pending, received)
+	}
+	return json.Marshal(snapshotL3{
+		Sequence: book.Sequence,
+		Time:     book.Time,
+		Bids:     book.Bids,
+		Asks:     book.Asks,
+		Pending:  pending,
+	})
+}
+
+// UnmarshalJSON restores a snapshot written by MarshalJSON or returned by the level3 REST endpoint,
+// replacing the whole book at once.
+func (book *L3) UnmarshalJSON(b []byte) (err error) {
+	var fresh = NewL3()
+	var s = snapshotL3{Bids: fresh.Bids, Asks: fresh.Asks}
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return
+	}
+	fresh.Sequence, fresh.Time = s.Sequence, s.Time
+	for _, received := range s.Pending {
+		fresh.pending[received.OrderId] = received
+	}
+	book.replace(fresh)
+	return
+}
+
+func (book *L2) MarshalBinary() (data []byte, err error) {
+	book.m.RLock()
+	defer book.m.RUnlock()
+	var e = newEncoder(magicL2, book.Sequence, book.Time)
+	for _, side := range []*sideL2{book.Bids, book.Asks} {
+		e.uvarint(uint64(len(side.sorted)))
+		side.walk(func(lv *priceLevel) bool {
+			e.decimal(lv.price)
+			e.decimal(lv.size)
+			return true
+		})
+	}
+	return e.w.Bytes(), nil
+}
+
+func (book *L2) UnmarshalBinary(data []byte) (err error) {
+	var fresh = NewL2()
+	var d = newDecoder(data)
+	fresh.Sequence, fresh.Time = d.header(magicL2)
+	for _, side := range []*sideL2{fresh.Bids, fresh.Asks} {
+		for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+			var price, size = d.decimal(), d.decimal()
+			var t int64
+			t, err = priceTick(price)
+			if err != nil {
+				return
+			}
+			side.set(t, price, size)
+		}
+	}
+	err = d.done()
+	if err != nil {
+		return
+	}
+	book.replace(fresh)
+	return
+}
+
+func (book *L3) MarshalBinary() (data []byte, err error) {
+	book.m.RLock()
+	defer book.m.RUnlock()
+	var e = newEncoder(magicL3, book.Sequence, book.Time)
+	for _, side := range []*sideL3{book.Bids, book.Asks} {
+		var count uint64
+		side.walk(func(lv *priceLevel) bool {
+			count += uint64(lv.count)
+			return true
+		})
+		e.uvarint(count)
+		side.walk(func(lv *priceLevel) bool {
+			for o := lv.head; o != nil; o = o.next {
+				e.string(o.Id)
+				e.decimal(o.Price)
+				e.decimal(o.Size)
+				e.varint(o.Time)
+			}
+			return true
+		})
+	}
+	e.uvarint(uint64(len(book.pending)))
+	for _, received := range book.pending {
+		e.varint(int64(received.Sequence))
+		for _, s := range []string{received.Symbol, received.Time, received.Side, received.OrderId,
+			received.Price, received.Size, received.ClientOid, received.OrderType} {
+			e.string(s)
+		}
+	}
+	return e.w.Bytes(), nil
+}
+
+func (book *L3) UnmarshalBinary(data []byte) (err error) {
+	var fresh = NewL3()
+	var d = newDecoder(data)
+	fresh.Sequence, fresh.Time = d.header(magicL3)
+	for _, side := range []*sideL3{fresh.Bids, fresh.Asks} {
+		for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+			var o = &OrderL3{Side: side.name}
+			o.Id = d.string()
+			o.Price = d.decimal()
+			o.tick, err = priceTick(o.Price)
+			if err != nil {
+				return
+			}
+			o.Size = d.decimal()
+			o.Time = d.varint()
+			side.put(o)
+		}
+	}
+	for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+		var received = new(ReceivedL3)
+		received.Sequence = Sequence(d.varint())
+		for _, s := range []*string{&received.Symbol, &received.Time, &received.Side, &received.OrderId,
+			&received.Price, &received.Size, &received.ClientOid, &received.OrderType} {
+			*s = d.string()
+		}
+		fresh.pending[received.OrderId] = received
+	}
+	err = d.done()
+	if err != nil {
+		return
+	}
+	book.replace(fresh)
+	return
+}
+
+func newEncoder(magic string, sequence Sequence, time int64) *encoder {
+	var e = &encoder{w: new(bytes.Buffer)}
+	e.w.WriteString(magic)
+	e.uvarint(snapshotVersion)
+	e.varint(int64(sequence))
+	e.varint(time)
+	return e
+}
+
+func (e *encoder) uvarint(v uint64) {
+	e.w.Write(e.buf[:binary.PutUvarint(e.buf[:], v)])
+}
+
+func (e *encoder) varint(v int64) {
+	e.w.Write(e.buf[:binary.PutVarint(e.buf[:], v)])
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.w.WriteString(s)
+}
+
+// decimal writes d as its exponent, sign and coefficient magnitude, a few bytes for the prices and
+// sizes a book holds.
+func (e *encoder) decimal(d decimal.Decimal) {
+	var c = d.Coefficient()
+	e.varint(int64(d.Exponent()))
+	e.varint(int64(c.Sign()))
+	var b = c.Bytes()
+	e.uvarint(uint64(len(b)))
+	e.w.Write(b)
+}
+
+func newDecoder(data []byte) *decoder {
+	return &decoder{r: bytes.NewReader(data)}
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = fmt.Errorf("%w: %v", ErrSnapshot, err)
+	}
+}
+
+func (d *decoder) header(magic string) (sequence Sequence, time int64) {
+	var b = make([]byte, len(magic))
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		d.fail(err)
+		return
+	}
+	if string(b) != magic {
+		d.fail(fmt.Errorf("magic %q", b))
+		return
+	}
+	if version := d.uvarint(); d.err == nil && version != snapshotVersion {
+		d.fail(fmt.Errorf("version %d", version))
+		return
+	}
+	sequence = Sequence(d.varint())
+	time = d.varint()
+	return
+}
+
+func (d *decoder) uvarint() (v uint64) {
+	if d.err != nil {
+		return
+	}
+	v, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		d.fail(err)
+	}
+	return
+}
+
+func (d *decoder) varint() (v int64) {
+	if d.err != nil {
+		return
+	}
+	v, err := binary.ReadVarint(d.r)
+	if err != nil {
+		d.fail(err)
+	}
+	return
+}
+
+func (d *decoder) bytes() (b []byte) {
+	var n = d.uvarint()
+	if d.err != nil {
+		return
+	}
+	if n > uint64(d.r.Len()) {
+		d.fail(fmt.Errorf("length %d", n))
+		return
+	}
+	b = make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		d.fail(err)
+	}
+	return
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) decimal() decimal.Decimal {
+	var exp = d.varint()
+	var sign = d.varint()
+	var c = new(big.Int).SetBytes(d.bytes())
+	if sign < 0 {
+		c.Neg(c)
+	}
+	return decimal.NewFromBigInt(c, int32(exp))
+}
+
+func (d *decoder) done() error {
+	if d.err == nil {
+		if _, err := d.r.ReadByte(); err != io.EOF {
+			d.fail(errors.New("trailing data"))
+		}
+	}
+	return d.err
+}
@@ -0,0 +1,104 @@
+package book
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// resets counts the resets of a book.
+type resets struct {
+	n int
+}
+
+func (r *resets) OnReset(sequence Sequence) { r.n++ }
+
+func (r *resets) OnTop(top Top) {}
+
+func (r *resets) OnLevel(update LevelUpdate) {}
+
+func (r *resets) OnTrade(trade Trade) {}
+
+func TestSyncSnapshot(t *testing.T) {
+	var source = NewL3()
+	if err := source.Add("a", Bids, "10.5", "1", "1600000000000"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Add("b", Asks, "11", "2", "1600000000000"); err != nil {
+		t.Fatal(err)
+	}
+	source.SetSequence(7)
+	l3Data, err := json.Marshal(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var l2Source = NewL2()
+	if err = l2Source.Set(Bids, "10.5", "1"); err != nil {
+		t.Fatal(err)
+	}
+	l2Source.SetSequence(7)
+	l2Data, err := json.Marshal(l2Source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var truncated = []byte(`{"sequence":"9","bids":[`)
+	tests := []struct {
+		name     string
+		l3, l2   []byte
+		sequence Sequence
+		resets   int
+		wantErr  bool
+	}{
+		{name: "replaces the book once", l3: l3Data, l2: l2Data, sequence: 7, resets: 1},
+		{name: "keeps the book on error", l3: truncated, l2: truncated, sequence: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run("l3 "+tt.name, func(t *testing.T) {
+			var l3 = NewL3()
+			l3.SetSequence(3)
+			var r = &resets{}
+			l3.Observe(r)
+			var s = NewSyncL3(l3, func(book *L3) error { return json.Unmarshal(tt.l3, book) })
+			if err = s.snapshot(); (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if l3.GetSequence() != tt.sequence || r.n != tt.resets {
+				t.Fatalf("sequence = %d, resets = %d, want %d and %d", l3.GetSequence(), r.n, tt.sequence, tt.resets)
+			}
+		})
+		t.Run("l2 "+tt.name, func(t *testing.T) {
+			var l2 = NewL2()
+			l2.SetSequence(3)
+			var r = &resets{}
+			l2.Observe(r)
+			var s = NewSyncL2(l2, func(book *L2) error { return json.Unmarshal(tt.l2, book) })
+			if err = s.snapshot(); (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if l2.GetSequence() != tt.sequence || r.n != tt.resets {
+				t.Fatalf("sequence = %d, resets = %d, want %d and %d", l2.GetSequence(), r.n, tt.sequence, tt.resets)
+			}
+		})
+	}
+}
+
+func TestSnapshotBinary(t *testing.T) {
+	var l3 = NewL3()
+	if err := l3.Add("a", Bids, "10.5", "1", "1600000000000"); err != nil {
+		t.Fatal(err)
+	}
+	l3.SetSequence(7)
+	data, err := l3.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored = NewL3()
+	if err = restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if best, ok := restored.BestBid(); !ok || restored.GetSequence() != 7 || best.Price.String() != "10.5" {
+		t.Fatalf("restored best bid = %v at %d", best, restored.GetSequence())
+	}
+	if err = restored.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Fatal("truncated snapshot restored")
+	}
+}
@@ -0,0 +1,214 @@
+package book
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	SyncRetryInterval = time.Second
+	SyncBufferSize    = 1 << 10
+	// SyncBufferLimit is the most updates buffered while a snapshot is fetched. Beyond it the
+	// buffer is dropped and the snapshot fetched again, as it would be too old to replay onto.
+	SyncBufferLimit = 1 << 16
+)
+
+const (
+	StateSyncing State = iota
+	StateLive
+	StateResyncing
+	StateClosed
+)
+
+type (
+	State int32
+
+	Update interface {
+		Range() (start, end Sequence)
+	}
+
+	Sync struct {
+		m        sync.Mutex
+		state    State
+		sequence func() Sequence
+		snapshot func() (err error)
+		decode   func(data []byte) (update Update, err error)
+		apply    func(update Update) (err error)
+		buffer   []Update
+		overflow bool
+		err      error
+		done     chan struct{}
+		once     sync.Once
+	}
+)
+
+func (state State) String() string {
+	switch state {
+	case StateSyncing:
+		return "syncing"
+	case StateLive:
+		return "live"
+	case StateResyncing:
+		return "resyncing"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+func (syncer *Sync) State() State {
+	syncer.m.Lock()
+	defer syncer.m.Unlock()
+	return syncer.state
+}
+
+func (syncer *Sync) Err() error {
+	syncer.m.Lock()
+	defer syncer.m.Unlock()
+	return syncer.err
+}
+
+func (syncer *Sync) Event(data []byte) (err error) {
+	var update Update
+	update, err = syncer.decode(data)
+	if err != nil {
+		return
+	}
+	syncer.m.Lock()
+	defer syncer.m.Unlock()
+	switch syncer.state {
+	case StateClosed:
+		return
+	case StateSyncing, StateResyncing:
+		if syncer.buffer == nil {
+			syncer.resync(syncer.state)
+		}
+		if len(syncer.buffer) >= SyncBufferLimit {
+			syncer.err = fmt.Errorf("book sync buffer overflow: %d", SyncBufferLimit)
+			syncer.buffer = make([]Update, 0, SyncBufferSize)
+			syncer.overflow = true
+		}
+		syncer.buffer = append(syncer.buffer, update)
+		return
+	}
+	var sequence = syncer.sequence()
+	var start, end = update.Range()
+	switch {
+	case end <= sequence:
+	case start > sequence+1:
+		syncer.resync(StateResyncing)
+		syncer.buffer = append(syncer.buffer, update)
+	default:
+		err = syncer.apply(update)
+		if err != nil {
+			syncer.err = err
+			if !errors.Is(err, ErrRejected) {
+				syncer.resync(StateResyncing)
+			}
+			err = nil
+		}
+	}
+	return
+}
+
+// Resync drops the book and fetches a fresh snapshot, unless one is already being fetched.
+func (syncer *Sync) Resync() {
+	syncer.m.Lock()
+	defer syncer.m.Unlock()
+	if syncer.buffer != nil || syncer.state == StateClosed {
+		return
+	}
+	syncer.resync(StateResyncing)
+}
+
+// Close stops the sync: updates are ignored from now on and a snapshot being fetched is not
+// retried or replayed onto.
+func (syncer *Sync) Close() {
+	syncer.once.Do(func() {
+		syncer.m.Lock()
+		defer syncer.m.Unlock()
+		syncer.state = StateClosed
+		syncer.buffer = nil
+		close(syncer.done)
+	})
+}
+
+// observe calls f with the sync lock held after every update applied without error or with changes
+// rejected by a guard.
+func (syncer *Sync) observe(f func(update Update)) {
+	syncer.m.Lock()
+	defer syncer.m.Unlock()
+	var apply = syncer.apply
+	syncer.apply = func(update Update) (err error) {
+		err = apply(update)
+		if err == nil || errors.Is(err, ErrRejected) {
+			f(update)
+		}
+		return
+	}
+}
+
+func (syncer *Sync) resync(state State) {
+	syncer.state = state
+	syncer.buffer = make([]Update, 0, SyncBufferSize)
+	go syncer.fetch()
+}
+
+// fetch loads snapshots until one can be replayed onto, fetching again right away when the
+// buffer overflowed meanwhile and after SyncRetryInterval on errors, until the sync is closed.
+func (syncer *Sync) fetch() {
+	for {
+		err := syncer.snapshot()
+		syncer.m.Lock()
+		if syncer.state == StateClosed {
+			syncer.m.Unlock()
+			return
+		}
+		if syncer.overflow {
+			syncer.overflow = false
+			syncer.m.Unlock()
+			continue
+		}
+		if err == nil {
+			err = syncer.replay()
+		}
+		syncer.err = err
+		if err == nil {
+			syncer.state = StateLive
+			syncer.buffer = nil
+			syncer.m.Unlock()
+			return
+		}
+		syncer.m.Unlock()
+		select {
+		case <-syncer.done:
+			return
+		case <-time.After(SyncRetryInterval):
+		}
+	}
+}
+
+func (syncer *Sync) replay() (err error) {
+	for _, update := range syncer.buffer {
+		var sequence = syncer.sequence()
+		var start, end = update.Range()
+		if end <= sequence {
+			continue
+		}
+		if start > sequence+1 {
+			err = fmt.Errorf("book sequence gap: [have:%d, start:%d]", sequence, start)
+			return
+		}
+		err = syncer.apply(update)
+		if errors.Is(err, ErrRejected) {
+			err = nil
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,123 @@
+package book
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bzeron/mk/kucoin"
+)
+
+type (
+	ChangesL2 struct {
+		Asks [][3]string `json:"asks"`
+		Bids [][3]string `json:"bids"`
+	}
+
+	UpdateL2 struct {
+		SequenceStart Sequence  `json:"sequenceStart"`
+		SequenceEnd   Sequence  `json:"sequenceEnd"`
+		Symbol        string    `json:"symbol"`
+		Changes       ChangesL2 `json:"changes"`
+	}
+)
+
+func (book *L2) Update(update *UpdateL2) (err error) {
+	book.m.Lock()
+	defer book.unlock()
+	var rejected, e error
+	rejected, err = book.update(Asks, update.Changes.Asks)
+	if err != nil {
+		return
+	}
+	e, err = book.update(Bids, update.Changes.Bids)
+	if err != nil {
+		return
+	}
+	if rejected == nil {
+		rejected = e
+	}
+	if update.SequenceEnd > book.Sequence {
+		book.Sequence = update.SequenceEnd
+	}
+	book.Time = time.Now().UnixNano()
+	err = rejected
+	return
+}
+
+// update sets changes of side. Changes the guard rejects are skipped, the first rejection is
+// returned once the rest are set.
+func (book *L2) update(side string, changes [][3]string) (rejected, err error) {
+	for _, change := range changes {
+		var sequence int64
+		sequence, err = strconv.ParseInt(change[2], 10, 64)
+		if err != nil {
+			return
+		}
+		if Sequence(sequence) <= book.Sequence {
+			continue
+		}
+		err = book.set(side, change[0], change[1])
+		if errors.Is(err, ErrRejected) {
+			if rejected == nil {
+				rejected = err
+			}
+			err = nil
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (update *UpdateL2) Range() (start, end Sequence) {
+	return update.SequenceStart, update.SequenceEnd
+}
+
+// SnapshotL2 fetches full level2 snapshots of symbol through client, for NewSyncL2. The endpoint
+// needs a client with auth.
+func SnapshotL2(client *kucoin.Client, symbol string) func(book *L2) (err error) {
+	return func(book *L2) (err error) {
+		var query = url.Values{}
+		query.Set("symbol", symbol)
+		var request *kucoin.CallRequest
+		request, err = client.NewCallRequest(http.MethodGet, "/api/v3/market/orderbook/level2", nil, query, nil)
+		if err != nil {
+			return
+		}
+		var buffer *bytes.Buffer
+		buffer, err = client.Send(request)
+		if err != nil {
+			return
+		}
+		err = json.NewDecoder(buffer).Decode(book)
+		return
+	}
+}
+
+// NewSyncL2 keeps book in sync with a level2 stream. snapshot loads a snapshot into book and must
+// replace it at once, as UnmarshalJSON and UnmarshalBinary do, or leave it as it was on error.
+func NewSyncL2(book *L2, snapshot func(book *L2) (err error)) *Sync {
+	return &Sync{
+		done:     make(chan struct{}),
+		sequence: book.GetSequence,
+		snapshot: func() (err error) {
+			return snapshot(book)
+		},
+		decode: func(data []byte) (update Update, err error) {
+			var u UpdateL2
+			err = json.Unmarshal(data, &u)
+			update = &u
+			return
+		},
+		apply: func(update Update) (err error) {
+			err = book.Update(update.(*UpdateL2))
+			return
+		},
+	}
+}
@@ -0,0 +1,185 @@
+package book
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bzeron/mk/kucoin"
+	"github.com/bzeron/mk/kucoin/kucointest"
+	"github.com/shopspring/decimal"
+)
+
+// levelsL2 lists side of book as price:size, best first.
+func levelsL2(book *L2, side string) string {
+	var s []string
+	book.walk(side, func(price, size decimal.Decimal) bool {
+		s = append(s, price.String()+":"+size.String())
+		return true
+	})
+	return strings.Join(s, ",")
+}
+
+func TestL2Update(t *testing.T) {
+	tests := []struct {
+		name     string
+		updates  []UpdateL2
+		bids     string
+		asks     string
+		sequence Sequence
+	}{
+		{
+			name: "applies in order",
+			updates: []UpdateL2{
+				{SequenceStart: 11, SequenceEnd: 12, Changes: ChangesL2{
+					Bids: [][3]string{{"10", "3", "11"}},
+					Asks: [][3]string{{"12", "1", "12"}},
+				}},
+				{SequenceStart: 13, SequenceEnd: 13, Changes: ChangesL2{Bids: [][3]string{{"9", "0", "13"}}}},
+			},
+			bids: "10:3", asks: "11:1,12:1", sequence: 13,
+		},
+		{
+			name: "skips changes at or below the sequence",
+			updates: []UpdateL2{
+				{SequenceStart: 9, SequenceEnd: 10, Changes: ChangesL2{
+					Bids: [][3]string{{"10", "5", "9"}, {"9", "0", "10"}},
+				}},
+			},
+			bids: "10:1,9:2", asks: "11:1", sequence: 10,
+		},
+		{
+			name: "applies the newer changes of a stale batch",
+			updates: []UpdateL2{
+				{SequenceStart: 9, SequenceEnd: 11, Changes: ChangesL2{
+					Bids: [][3]string{{"10", "5", "10"}, {"9", "4", "11"}},
+				}},
+			},
+			bids: "10:1,9:4", asks: "11:1", sequence: 11,
+		},
+		{
+			name: "skips a duplicate batch",
+			updates: []UpdateL2{
+				{SequenceStart: 11, SequenceEnd: 11, Changes: ChangesL2{Asks: [][3]string{{"11", "2", "11"}}}},
+				{SequenceStart: 11, SequenceEnd: 11, Changes: ChangesL2{Asks: [][3]string{{"11", "0", "11"}}}},
+			},
+			bids: "10:1,9:2", asks: "11:2", sequence: 11,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var book = NewL2()
+			book.SetSequence(10)
+			for _, level := range [][3]string{{Bids, "10", "1"}, {Bids, "9", "2"}, {Asks, "11", "1"}} {
+				if err := book.Set(level[0], level[1], level[2]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := range tt.updates {
+				if err := book.Update(&tt.updates[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := levelsL2(book, Bids); got != tt.bids {
+				t.Fatalf("bids = %s, want %s", got, tt.bids)
+			}
+			if got := levelsL2(book, Asks); got != tt.asks {
+				t.Fatalf("asks = %s, want %s", got, tt.asks)
+			}
+			if got := book.GetSequence(); got != tt.sequence {
+				t.Fatalf("sequence = %d, want %d", got, tt.sequence)
+			}
+		})
+	}
+}
+
+func TestSyncL2Gap(t *testing.T) {
+	var snapshots = []string{
+		`{"sequence":"10","bids":[["10","1"]],"asks":[["11","1"]]}`,
+		`{"sequence":"14","bids":[["10","4"]],"asks":[["11","1"]]}`,
+	}
+	var fetched int32
+	var book = NewL2()
+	var syncer = NewSyncL2(book, func(book *L2) (err error) {
+		var i = int(atomic.AddInt32(&fetched, 1)) - 1
+		if i >= len(snapshots) {
+			return errors.New("no snapshot")
+		}
+		return json.Unmarshal([]byte(snapshots[i]), book)
+	})
+	defer syncer.Close()
+	var event = func(start, end Sequence, bids ...[3]string) {
+		t.Helper()
+		data, err := json.Marshal(UpdateL2{SequenceStart: start, SequenceEnd: end, Changes: ChangesL2{Bids: bids}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = syncer.Event(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var wait = func(sequence Sequence) {
+		t.Helper()
+		var deadline = time.Now().Add(2 * time.Second)
+		for syncer.State() != StateLive || book.GetSequence() != sequence {
+			if time.Now().After(deadline) {
+				t.Fatalf("state = %s, sequence = %d, want %s at %d", syncer.State(), book.GetSequence(), StateLive, sequence)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	event(11, 11, [3]string{"10", "2", "11"})
+	wait(11)
+	// 12 and 13 are missing, so the book must be reloaded rather than jump to 14.
+	event(14, 14, [3]string{"10", "3", "14"})
+	wait(14)
+	if got := atomic.LoadInt32(&fetched); got != 2 {
+		t.Fatalf("fetched = %d, want 2", got)
+	}
+	if got := levelsL2(book, Bids); got != "10:4" {
+		t.Fatalf("bids = %s, want 10:4", got)
+	}
+}
+
+func TestSnapshotL2(t *testing.T) {
+	var server = kucointest.NewServer()
+	defer server.Close()
+	server.SetCredentials("key", "secret", "passphrase")
+	server.SetMarket("/api/v3/market/orderbook/level2", "BTC-USDT", map[string]interface{}{
+		"sequence": "42",
+		"time":     1600000000000,
+		"bids":     [][2]string{{"10", "1"}, {"9", "2"}},
+		"asks":     [][2]string{{"11", "3"}},
+	})
+	client, err := server.Client(kucoin.WithAuth("key", "secret", "passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		symbol  string
+		wantErr bool
+	}{
+		{name: "loads the book", symbol: "BTC-USDT"},
+		{name: "unknown symbol", symbol: "ETH-USDT", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var book = NewL2()
+			err := SnapshotL2(client, tt.symbol)(book)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if book.GetSequence() != 42 || levelsL2(book, Bids) != "10:1,9:2" || levelsL2(book, Asks) != "11:3" {
+				t.Fatalf("book = %d %s / %s", book.GetSequence(), levelsL2(book, Bids), levelsL2(book, Asks))
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package book
+
+// NewSyncL3 keeps book in sync with a level3 stream. snapshot loads a snapshot into book and must
+// replace it at once, as UnmarshalJSON and UnmarshalBinary do, or leave it as it was on error.
+func NewSyncL3(book *L3, snapshot func(book *L3) (err error)) *Sync {
+	return &Sync{
+		done:     make(chan struct{}),
+		sequence: book.GetSequence,
+		snapshot: func() (err error) {
+			return snapshot(book)
+		},
+		decode: func(data []byte) (update Update, err error) {
+			update, err = DecodeL3(data)
+			return
+		},
+		apply: func(update Update) (err error) {
+			err = book.Apply(update.(EventL3))
+			return
+		},
+	}
+}
@@ -0,0 +1,164 @@
+package book
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testUpdate [2]Sequence
+
+func (update testUpdate) Range() (start, end Sequence) {
+	return update[0], update[1]
+}
+
+// testSync is a Sync over a book that only keeps its sequence. Each snapshot takes the next
+// sequence of snapshots, waiting on gate when it is set.
+type testSync struct {
+	*Sync
+	sequence  int64
+	snapshots []Sequence
+	fetched   int32
+	gate      chan struct{}
+}
+
+func newTestSync(snapshots ...Sequence) *testSync {
+	var ts = &testSync{snapshots: snapshots}
+	ts.Sync = &Sync{
+		done: make(chan struct{}),
+		sequence: func() Sequence {
+			return Sequence(atomic.LoadInt64(&ts.sequence))
+		},
+		snapshot: func() (err error) {
+			if ts.gate != nil {
+				<-ts.gate
+			}
+			var i = int(atomic.AddInt32(&ts.fetched, 1)) - 1
+			if i >= len(ts.snapshots) {
+				return errors.New("no snapshot")
+			}
+			atomic.StoreInt64(&ts.sequence, int64(ts.snapshots[i]))
+			return
+		},
+		decode: func(data []byte) (update Update, err error) {
+			var u testUpdate
+			err = json.Unmarshal(data, &u)
+			update = u
+			return
+		},
+		apply: func(update Update) (err error) {
+			var start, end = update.Range()
+			if !atomic.CompareAndSwapInt64(&ts.sequence, int64(start-1), int64(end)) {
+				err = fmt.Errorf("apply %d after %d", start, atomic.LoadInt64(&ts.sequence))
+			}
+			return
+		},
+	}
+	return ts
+}
+
+func (ts *testSync) event(t *testing.T, start, end Sequence) {
+	t.Helper()
+	err := ts.Event([]byte(fmt.Sprintf("[%d,%d]", start, end)))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (ts *testSync) wait(t *testing.T, state State) {
+	t.Helper()
+	var deadline = time.Now().Add(2 * time.Second)
+	for ts.State() != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %s, want %s", ts.State(), state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSync(t *testing.T) {
+	defer func(interval time.Duration) { SyncRetryInterval = interval }(SyncRetryInterval)
+	SyncRetryInterval = time.Millisecond
+	tests := []struct {
+		name      string
+		snapshots []Sequence
+		events    [][2]Sequence
+		want      Sequence
+		fetched   int32
+	}{
+		{name: "replays buffered updates after the snapshot", snapshots: []Sequence{2}, events: [][2]Sequence{{1, 1}, {2, 2}, {3, 4}}, want: 4, fetched: 1},
+		{name: "refetches a snapshot older than the buffer", snapshots: []Sequence{1, 3}, events: [][2]Sequence{{3, 3}, {4, 4}}, want: 4, fetched: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ts = newTestSync(tt.snapshots...)
+			ts.gate = make(chan struct{})
+			for _, e := range tt.events {
+				ts.event(t, e[0], e[1])
+			}
+			close(ts.gate)
+			ts.wait(t, StateLive)
+			if got := ts.sequence; Sequence(got) != tt.want {
+				t.Fatalf("sequence = %d, want %d", got, tt.want)
+			}
+			if got := atomic.LoadInt32(&ts.fetched); got != tt.fetched {
+				t.Fatalf("fetched = %d, want %d", got, tt.fetched)
+			}
+		})
+	}
+}
+
+func TestSyncGapResyncs(t *testing.T) {
+	var ts = newTestSync(2, 5)
+	ts.event(t, 1, 2)
+	ts.wait(t, StateLive)
+	ts.event(t, 5, 5)
+	ts.wait(t, StateLive)
+	if ts.sequence != 5 {
+		t.Fatalf("sequence = %d, want 5", ts.sequence)
+	}
+}
+
+func TestSyncBufferLimit(t *testing.T) {
+	defer func(limit int) { SyncBufferLimit = limit }(SyncBufferLimit)
+	SyncBufferLimit = 4
+	var ts = newTestSync(2, 8)
+	ts.gate = make(chan struct{})
+	for i := Sequence(1); i <= 10; i++ {
+		ts.event(t, i, i)
+	}
+	ts.m.Lock()
+	var buffered = len(ts.buffer)
+	ts.m.Unlock()
+	if buffered > SyncBufferLimit {
+		t.Fatalf("buffered = %d, want at most %d", buffered, SyncBufferLimit)
+	}
+	close(ts.gate)
+	ts.wait(t, StateLive)
+	if atomic.LoadInt32(&ts.fetched) != 2 || ts.sequence != 10 {
+		t.Fatalf("fetched = %d, sequence = %d, want a second snapshot replayed to 10", ts.fetched, ts.sequence)
+	}
+}
+
+func TestSyncClose(t *testing.T) {
+	var ts = newTestSync()
+	ts.gate = make(chan struct{})
+	ts.event(t, 1, 1)
+	ts.Close()
+	close(ts.gate)
+	time.Sleep(10 * time.Millisecond)
+	if got := atomic.LoadInt32(&ts.fetched); got != 1 {
+		t.Fatalf("fetched = %d after close, want 1", got)
+	}
+	ts.event(t, 2, 2)
+	if ts.State() != StateClosed {
+		t.Fatalf("state = %s, want %s", ts.State(), StateClosed)
+	}
+	ts.Resync()
+	if ts.State() != StateClosed {
+		t.Fatalf("state after resync = %s, want %s", ts.State(), StateClosed)
+	}
+}
@@ -0,0 +1,146 @@
+package book
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var TapeSize = 1 << 16
+
+type (
+	TapeStats struct {
+		From       time.Time       `json:"from"`
+		To         time.Time       `json:"to"`
+		Count      int             `json:"count"`
+		BuyCount   int             `json:"buyCount"`
+		SellCount  int             `json:"sellCount"`
+		Volume     decimal.Decimal `json:"volume"`
+		BuyVolume  decimal.Decimal `json:"buyVolume"`
+		SellVolume decimal.Decimal `json:"sellVolume"`
+		Notional   decimal.Decimal `json:"notional"`
+		VWAP       decimal.Decimal `json:"vwap"`
+		Imbalance  decimal.Decimal `json:"imbalance"`
+		Open       decimal.Decimal `json:"open"`
+		High       decimal.Decimal `json:"high"`
+		Low        decimal.Decimal `json:"low"`
+		Close      decimal.Decimal `json:"close"`
+	}
+
+	// Tape is a time and sales record fed by observing an L3. It keeps trades no older than retention
+	// behind the latest one and at most size of them.
+	Tape struct {
+		m         sync.RWMutex
+		retention time.Duration
+		size      int
+		trades    []Trade
+	}
+)
+
+func NewTape(retention time.Duration, size int) *Tape {
+	if size <= 0 {
+		size = TapeSize
+	}
+	return &Tape{retention: retention, size: size}
+}
+
+func (tape *Tape) OnReset(Sequence) {}
+
+func (tape *Tape) OnTop(Top) {}
+
+func (tape *Tape) OnLevel(LevelUpdate) {}
+
+func (tape *Tape) OnTrade(trade Trade) {
+	tape.m.Lock()
+	defer tape.m.Unlock()
+	tape.trades = append(tape.trades, trade)
+	var drop = len(tape.trades) - tape.size
+	if tape.retention > 0 {
+		var oldest = trade.Time - int64(tape.retention)
+		if i := tape.search(oldest); i > drop {
+			drop = i
+		}
+	}
+	if drop <= 0 {
+		return
+	}
+	tape.trades = tape.trades[drop:]
+	if cap(tape.trades) > 2*tape.size {
+		tape.trades = append(make([]Trade, 0, tape.size), tape.trades...)
+	}
+}
+
+// search returns the index of the first trade at or after t nanoseconds.
+func (tape *Tape) search(t int64) int {
+	return sort.Search(len(tape.trades), func(i int) bool {
+		return tape.trades[i].Time >= t
+	})
+}
+
+func (tape *Tape) Len() int {
+	tape.m.RLock()
+	defer tape.m.RUnlock()
+	return len(tape.trades)
+}
+
+// Last returns up to n of the latest trades, newest first.
+func (tape *Tape) Last(n int) (trades []Trade) {
+	tape.m.RLock()
+	defer tape.m.RUnlock()
+	if n > len(tape.trades) {
+		n = len(tape.trades)
+	}
+	trades = make([]Trade, n)
+	for i := range trades {
+		trades[i] = tape.trades[len(tape.trades)-1-i]
+	}
+	return
+}
+
+// Between returns the trades in [from, to), oldest first.
+func (tape *Tape) Between(from, to time.Time) (trades []Trade) {
+	tape.m.RLock()
+	defer tape.m.RUnlock()
+	var i, j = tape.search(from.UnixNano()), tape.search(to.UnixNano())
+	return append([]Trade(nil), tape.trades[i:j]...)
+}
+
+// Stats aggregates the trades of the last window up to now.
+func (tape *Tape) Stats(window time.Duration) TapeStats {
+	var now = time.Now()
+	return tape.StatsBetween(now.Add(-window), now)
+}
+
+// StatsBetween aggregates the trades in [from, to).
+func (tape *Tape) StatsBetween(from, to time.Time) (stats TapeStats) {
+	tape.m.RLock()
+	defer tape.m.RUnlock()
+	stats.From, stats.To = from, to
+	var i, j = tape.search(from.UnixNano()), tape.search(to.UnixNano())
+	for _, trade := range tape.trades[i:j] {
+		if stats.Count == 0 {
+			stats.Open, stats.High, stats.Low = trade.Price, trade.Price, trade.Price
+		}
+		stats.Count++
+		stats.Close = trade.Price
+		stats.High = decimal.Max(stats.High, trade.Price)
+		stats.Low = decimal.Min(stats.Low, trade.Price)
+		stats.Volume = stats.Volume.Add(trade.Size)
+		stats.Notional = stats.Notional.Add(trade.Size.Mul(trade.Price))
+		switch trade.Side {
+		case Bids:
+			stats.BuyCount++
+			stats.BuyVolume = stats.BuyVolume.Add(trade.Size)
+		case Asks:
+			stats.SellCount++
+			stats.SellVolume = stats.SellVolume.Add(trade.Size)
+		}
+	}
+	if !stats.Volume.IsZero() {
+		stats.VWAP = stats.Notional.Div(stats.Volume)
+		stats.Imbalance = stats.BuyVolume.Sub(stats.SellVolume).Div(stats.Volume)
+	}
+	return
+}
@@ -0,0 +1,127 @@
+package book
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// tapeBook returns an L3 observed by a tape of size and retention with a resting ask "a" and bid
+// "b", and a function that matches a taker of side against the maker at ms milliseconds.
+func tapeBook(t *testing.T, retention time.Duration, size int) (*Tape, func(side, size string, ms int64)) {
+	var book, tape = NewL3(), NewTape(retention, size)
+	book.Observe(tape)
+	var sequence Sequence
+	var apply = func(data string) {
+		t.Helper()
+		sequence++
+		e, err := DecodeL3([]byte(fmt.Sprintf(`{"sequence":"%d","symbol":"BTC-USDT",%s}`, sequence, data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = book.Apply(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	apply(`"type":"open","time":"1600000000000","orderId":"a","side":"sell","price":"11","size":"1000"`)
+	apply(`"type":"open","time":"1600000000000","orderId":"b","side":"buy","price":"10","size":"1000"`)
+	return tape, func(side, size string, ms int64) {
+		t.Helper()
+		var maker, price = "a", "11"
+		if side == Asks {
+			maker, price = "b", "10"
+		}
+		apply(fmt.Sprintf(`"type":"match","time":"%d","side":%q,"price":%q,"size":%q,"tradeId":"t%d","takerOrderId":"x","makerOrderId":%q`,
+			ms, side, price, size, sequence+1, maker))
+	}
+}
+
+func TestTapePrints(t *testing.T) {
+	tape, match := tapeBook(t, 0, 0)
+	match(Bids, "1", 1600000000001)
+	match(Asks, "2", 1600000000002)
+	var trades = tape.Last(10)
+	if len(trades) != 2 {
+		t.Fatalf("trades = %d, want 2", len(trades))
+	}
+	tests := []struct {
+		name  string
+		trade Trade
+		want  string
+	}{
+		{name: "sell", trade: trades[0], want: "sell 10 2 maker:b taker:x time:1600000000002000000"},
+		{name: "buy", trade: trades[1], want: "buy 11 1 maker:a taker:x time:1600000000001000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got = fmt.Sprintf("%s %s %s maker:%s taker:%s time:%d", tt.trade.Side, tt.trade.Price, tt.trade.Size,
+				tt.trade.MakerOrderId, tt.trade.TakerOrderId, tt.trade.Time)
+			if got != tt.want {
+				t.Fatalf("print = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTapeStats(t *testing.T) {
+	tape, match := tapeBook(t, 0, 0)
+	match(Bids, "1", 1600000000000)
+	match(Bids, "3", 1600000001000)
+	match(Asks, "4", 1600000002000)
+	var from = time.Unix(1600000000, 0)
+	tests := []struct {
+		name string
+		to   time.Duration
+		want string
+	}{
+		{name: "buys only", to: 2 * time.Second, want: "count:2 buy:2/4 sell:0/0 vwap:11 ohlc:11/11/11/11"},
+		{name: "both sides", to: 3 * time.Second, want: "count:3 buy:2/4 sell:1/4 vwap:10.5 ohlc:11/11/10/10"},
+		{name: "empty", to: 0, want: "count:0 buy:0/0 sell:0/0 vwap:0 ohlc:0/0/0/0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s = tape.StatsBetween(from, from.Add(tt.to))
+			var got = fmt.Sprintf("count:%d buy:%d/%s sell:%d/%s vwap:%s ohlc:%s/%s/%s/%s", s.Count, s.BuyCount, s.BuyVolume,
+				s.SellCount, s.SellVolume, s.VWAP, s.Open, s.High, s.Low, s.Close)
+			if got != tt.want {
+				t.Fatalf("stats = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTapeWraparound(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention time.Duration
+		size      int
+		trades    int
+		want      string
+	}{
+		{name: "under size", size: 4, trades: 3, want: "[3 2 1]"},
+		{name: "wraps at size", size: 3, trades: 5, want: "[5 4 3]"},
+		{name: "wraps many times", size: 2, trades: 20, want: "[20 19]"},
+		{name: "drops past retention", retention: 2 * time.Second, size: 10, trades: 6, want: "[6 5 4]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tape, match := tapeBook(t, tt.retention, tt.size)
+			for i := 1; i <= tt.trades; i++ {
+				match(Bids, fmt.Sprint(i), 1600000000000+int64(i)*1000)
+			}
+			var sizes []string
+			for _, trade := range tape.Last(tt.trades) {
+				sizes = append(sizes, trade.Size.String())
+			}
+			if got := fmt.Sprint(sizes); got != tt.want {
+				t.Fatalf("last = %s, want %s", got, tt.want)
+			}
+			if tape.Len() != len(sizes) {
+				t.Fatalf("len = %d, want %d", tape.Len(), len(sizes))
+			}
+			if cap(tape.trades) > 2*tt.size {
+				t.Fatalf("cap = %d, want at most %d", cap(tape.trades), 2*tt.size)
+			}
+		})
+	}
+}
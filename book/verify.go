@@ -0,0 +1,295 @@
+package book
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	DiscrepancyMissing    = "missing"
+	DiscrepancyUnexpected = "unexpected"
+	DiscrepancySize       = "size"
+	DiscrepancyPrice      = "price"
+	DiscrepancyNegative   = "negative"
+	DiscrepancyCrossed    = "crossed"
+)
+
+var (
+	VerifyTimeout  = 5 * time.Second
+	VerifyInterval = 50 * time.Millisecond
+
+	ErrNotLive = errors.New("book not live")
+)
+
+type (
+	// Discrepancy is one difference between the live book and a snapshot. Id is empty for L2 levels,
+	// missing entries exist only in the snapshot and unexpected ones only in the live book. For a
+	// crossed book Price is the best bid and Live the best ask.
+	Discrepancy struct {
+		Kind     string          `json:"kind"`
+		Side     string          `json:"side"`
+		Id       string          `json:"id,omitempty"`
+		Price    decimal.Decimal `json:"price"`
+		Live     decimal.Decimal `json:"live"`
+		Snapshot decimal.Decimal `json:"snapshot"`
+	}
+
+	Report struct {
+		Sequence      Sequence      `json:"sequence"`
+		Time          time.Time     `json:"time"`
+		Discrepancies []Discrepancy `json:"discrepancies"`
+		Resync        bool          `json:"resync"`
+	}
+
+	// Verifier checks a live book against a fresh snapshot. The snapshot is rolled forward with the
+	// updates the live book applied while it was fetched, so both are compared at the same sequence.
+	Verifier struct {
+		m         sync.Mutex
+		syncer    *Sync
+		resync    bool
+		recording bool
+		updates   []Update
+		fetch     func() (sequence Sequence, err error)
+		compare   func(updates []Update) (report Report, err error)
+	}
+)
+
+func (report Report) OK() bool {
+	return len(report.Discrepancies) == 0
+}
+
+func newVerifier(syncer *Sync, resync bool) (v *Verifier) {
+	v = &Verifier{syncer: syncer, resync: resync}
+	syncer.observe(func(update Update) {
+		v.m.Lock()
+		defer v.m.Unlock()
+		if v.recording {
+			v.updates = append(v.updates, update)
+		}
+	})
+	return
+}
+
+// NewVerifierL3 checks book, kept by syncer, against snapshots fetched with snapshot. With resync a
+// check that finds discrepancies makes syncer fetch a new book.
+func NewVerifierL3(book *L3, syncer *Sync, snapshot func(book *L3) (err error), resync bool) (v *Verifier) {
+	v = newVerifier(syncer, resync)
+	var fresh *L3
+	v.fetch = func() (sequence Sequence, err error) {
+		fresh = NewL3()
+		err = snapshot(fresh)
+		sequence = fresh.Sequence
+		return
+	}
+	v.compare = func(updates []Update) (report Report, err error) {
+		for _, update := range updates {
+			var _, end = update.Range()
+			if end <= fresh.Sequence {
+				continue
+			}
+			// an order the live book knew before the snapshot may be missing from it; Apply leaves
+			// the sequence behind on ErrUnknownOrder, so step over the update.
+			err = fresh.Apply(update.(EventL3))
+			if errors.Is(err, ErrUnknownOrder) {
+				fresh.SetSequence(end)
+				err = nil
+			}
+			if err != nil {
+				return
+			}
+		}
+		book.m.RLock()
+		defer book.m.RUnlock()
+		if book.Sequence != fresh.Sequence {
+			err = fmt.Errorf("%w: [live:%d, snapshot:%d]", ErrSequence, book.Sequence, fresh.Sequence)
+			return
+		}
+		report.Sequence = book.Sequence
+		for id, want := range fresh.orders {
+			have, found := book.orders[id]
+			switch {
+			case !found:
+				report.add(DiscrepancyMissing, want.Side, id, want.Price, decimal.Zero, want.Size)
+			case !have.Price.Equal(want.Price):
+				report.add(DiscrepancyPrice, have.Side, id, have.Price, have.Size, want.Size)
+			case !have.Size.Equal(want.Size):
+				report.add(DiscrepancySize, have.Side, id, have.Price, have.Size, want.Size)
+			}
+		}
+		for id, have := range book.orders {
+			if have.Size.Sign() <= 0 {
+				report.add(DiscrepancyNegative, have.Side, id, have.Price, have.Size, decimal.Zero)
+			}
+			if _, found := fresh.orders[id]; !found {
+				report.add(DiscrepancyUnexpected, have.Side, id, have.Price, have.Size, decimal.Zero)
+			}
+		}
+		report.crossed(&book.Depth)
+		return
+	}
+	return
+}
+
+// NewVerifierL2 checks book, kept by syncer, against snapshots fetched with snapshot. With resync a
+// check that finds discrepancies makes syncer fetch a new book.
+func NewVerifierL2(book *L2, syncer *Sync, snapshot func(book *L2) (err error), resync bool) (v *Verifier) {
+	v = newVerifier(syncer, resync)
+	var fresh *L2
+	v.fetch = func() (sequence Sequence, err error) {
+		fresh = NewL2()
+		err = snapshot(fresh)
+		sequence = fresh.Sequence
+		return
+	}
+	v.compare = func(updates []Update) (report Report, err error) {
+		for _, update := range updates {
+			if _, end := update.Range(); end <= fresh.Sequence {
+				continue
+			}
+			err = fresh.Update(update.(*UpdateL2))
+			if err != nil {
+				return
+			}
+		}
+		book.m.RLock()
+		defer book.m.RUnlock()
+		if book.Sequence != fresh.Sequence {
+			err = fmt.Errorf("%w: [live:%d, snapshot:%d]", ErrSequence, book.Sequence, fresh.Sequence)
+			return
+		}
+		report.Sequence = book.Sequence
+		for _, side := range []string{Bids, Asks} {
+			var have, want = book.side(side), fresh.side(side)
+			for t, lv := range want.levels {
+				live, found := have.levels[t]
+				switch {
+				case !found:
+					report.add(DiscrepancyMissing, side, "", lv.price, decimal.Zero, lv.size)
+				case !live.size.Equal(lv.size):
+					report.add(DiscrepancySize, side, "", lv.price, live.size, lv.size)
+				}
+			}
+			for t, lv := range have.levels {
+				if lv.size.Sign() <= 0 {
+					report.add(DiscrepancyNegative, side, "", lv.price, lv.size, decimal.Zero)
+				}
+				if _, found := want.levels[t]; !found {
+					report.add(DiscrepancyUnexpected, side, "", lv.price, lv.size, decimal.Zero)
+				}
+			}
+		}
+		report.crossed(&book.Depth)
+		return
+	}
+	return
+}
+
+func (report *Report) add(kind, side, id string, price, live, snapshot decimal.Decimal) {
+	report.Discrepancies = append(report.Discrepancies, Discrepancy{
+		Kind:     kind,
+		Side:     side,
+		Id:       id,
+		Price:    price,
+		Live:     live,
+		Snapshot: snapshot,
+	})
+}
+
+func (report *Report) crossed(d *Depth) {
+	bid, ask, ok := d.top()
+	if ok && bid.Price.GreaterThanOrEqual(ask.Price) {
+		report.add(DiscrepancyCrossed, "", "", bid.Price, ask.Price, decimal.Zero)
+	}
+}
+
+// Check fetches a snapshot and compares the live book with it once the live book has reached the
+// snapshot sequence, waiting up to VerifyTimeout.
+func (v *Verifier) Check() (report Report, err error) {
+	if v.syncer.State() != StateLive {
+		err = ErrNotLive
+		return
+	}
+	v.m.Lock()
+	v.recording = true
+	v.updates = nil
+	v.m.Unlock()
+	defer func() {
+		v.m.Lock()
+		v.recording = false
+		v.updates = nil
+		v.m.Unlock()
+	}()
+	var sequence Sequence
+	sequence, err = v.fetch()
+	if err != nil {
+		return
+	}
+	for deadline := time.Now().Add(VerifyTimeout); v.syncer.sequence() < sequence; {
+		if time.Now().After(deadline) {
+			err = fmt.Errorf("%w: live book behind snapshot %d", ErrSequence, sequence)
+			return
+		}
+		time.Sleep(VerifyInterval)
+	}
+	v.syncer.m.Lock()
+	if v.syncer.state != StateLive {
+		v.syncer.m.Unlock()
+		err = ErrNotLive
+		return
+	}
+	v.m.Lock()
+	var updates = v.updates
+	v.m.Unlock()
+	report, err = v.compare(updates)
+	v.syncer.m.Unlock()
+	if err != nil {
+		return
+	}
+	report.Time = time.Now()
+	sort.Slice(report.Discrepancies, func(i, j int) bool {
+		var a, b = report.Discrepancies[i], report.Discrepancies[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Side != b.Side {
+			return a.Side < b.Side
+		}
+		if !a.Price.Equal(b.Price) {
+			return a.Price.LessThan(b.Price)
+		}
+		return a.Id < b.Id
+	})
+	if !report.OK() && v.resync {
+		report.Resync = true
+		v.syncer.Resync()
+	}
+	return
+}
+
+// Start runs Check every interval and hands each result to f until stop is called.
+func (v *Verifier) Start(interval time.Duration, f func(report Report, err error)) (stop func()) {
+	var done = make(chan struct{})
+	var once sync.Once
+	go func() {
+		var ticker = time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				f(v.Check())
+			}
+		}
+	}()
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
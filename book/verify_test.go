@@ -0,0 +1,159 @@
+package book
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestVerifierL3(t *testing.T) {
+	tests := []struct {
+		name   string
+		live   func(t *testing.T, book *L3)
+		events []string // applied to the live book while the snapshot is fetched
+		resync bool
+		want   []string
+	}{
+		{name: "in sync"},
+		{
+			name: "rolls forward past an order missing from the snapshot",
+			live: func(t *testing.T, book *L3) {
+				if err := book.Apply(event(t, TypeReceived, 6, `"orderId":"p","side":"buy","price":"10","size":"1"`)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			events: []string{
+				`{"type":"done","sequence":"6","symbol":"BTC-USDT","time":"1600000000000","orderId":"p","side":"buy","reason":"canceled"}`,
+				`{"type":"open","sequence":"7","symbol":"BTC-USDT","time":"1600000000000","orderId":"x","side":"buy","price":"9","size":"1"}`,
+			},
+		},
+		{
+			name: "size",
+			live: func(t *testing.T, book *L3) {
+				if err := book.NewSize("a", "0.5"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{DiscrepancySize},
+		},
+		{
+			name:   "missing with resync",
+			live:   func(t *testing.T, book *L3) { book.Del("a") },
+			resync: true,
+			want:   []string{DiscrepancyMissing},
+		},
+		{
+			name: "unexpected and crossed",
+			live: func(t *testing.T, book *L3) {
+				if err := book.Add("c", Bids, "12", "1", "1600000000000"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{DiscrepancyCrossed, DiscrepancyUnexpected},
+		},
+	}
+	var truth = NewL3()
+	for _, o := range [][3]string{{"a", Bids, "10"}, {"b", Asks, "11"}} {
+		if err := truth.Add(o[0], o[1], o[2], "1", "1600000000000"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	truth.SetSequence(5)
+	data, err := json.Marshal(truth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot = func(book *L3) error { return json.Unmarshal(data, book) }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var live = NewL3()
+			if err := snapshot(live); err != nil {
+				t.Fatal(err)
+			}
+			if tt.live != nil {
+				tt.live(t, live)
+				live.SetSequence(5)
+			}
+			var syncer = NewSyncL3(live, snapshot)
+			defer syncer.Close()
+			syncer.state = StateLive
+			var fetch = func(book *L3) error {
+				for _, e := range tt.events {
+					if err := syncer.Event([]byte(e)); err != nil {
+						return err
+					}
+				}
+				return snapshot(book)
+			}
+			report, err := NewVerifierL3(live, syncer, fetch, tt.resync).Check()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var kinds []string
+			for _, d := range report.Discrepancies {
+				kinds = append(kinds, d.Kind)
+			}
+			if len(kinds) != len(tt.want) {
+				t.Fatalf("discrepancies = %v, want %v", kinds, tt.want)
+			}
+			for i := range kinds {
+				if kinds[i] != tt.want[i] {
+					t.Fatalf("discrepancies = %v, want %v", kinds, tt.want)
+				}
+			}
+			if report.Resync != (tt.resync && !report.OK()) {
+				t.Fatalf("resync = %v", report.Resync)
+			}
+		})
+	}
+}
+
+func TestVerifierL2(t *testing.T) {
+	tests := []struct {
+		name string
+		live [3]string
+		want []string
+	}{
+		{name: "in sync", live: [3]string{Bids, "10", "1"}},
+		{name: "size", live: [3]string{Bids, "10", "2"}, want: []string{DiscrepancySize}},
+		{name: "missing", live: [3]string{Bids, "10", "0"}, want: []string{DiscrepancyMissing}},
+		{name: "unexpected", live: [3]string{Bids, "9", "1"}, want: []string{DiscrepancyUnexpected}},
+	}
+	var truth = NewL2()
+	for _, level := range [][3]string{{Bids, "10", "1"}, {Asks, "11", "1"}} {
+		if err := truth.Set(level[0], level[1], level[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	truth.SetSequence(5)
+	data, err := json.Marshal(truth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snapshot = func(book *L2) error { return json.Unmarshal(data, book) }
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var live = NewL2()
+			if err := snapshot(live); err != nil {
+				t.Fatal(err)
+			}
+			if err := live.Set(tt.live[0], tt.live[1], tt.live[2]); err != nil {
+				t.Fatal(err)
+			}
+			var syncer = NewSyncL2(live, snapshot)
+			defer syncer.Close()
+			syncer.state = StateLive
+			report, err := NewVerifierL2(live, syncer, snapshot, false).Check()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Discrepancies) != len(tt.want) {
+				t.Fatalf("discrepancies = %+v, want %v", report.Discrepancies, tt.want)
+			}
+			for i, d := range report.Discrepancies {
+				if d.Kind != tt.want[i] {
+					t.Fatalf("discrepancies = %+v, want %v", report.Discrepancies, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,495 @@
+package fixed
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+const MaxScale = 18
+
+var (
+	ErrSyntax    = errors.New("fixed invalid syntax")
+	ErrPrecision = errors.New("fixed precision exceeds scale")
+	ErrRange     = errors.New("fixed value out of range")
+	ErrScale     = errors.New("fixed scale out of range")
+	ErrDivision  = errors.New("fixed division by zero")
+)
+
+var pow10 = [MaxScale + 1]int64{
+	1, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9,
+	1e10, 1e11, 1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18,
+}
+
+// Number is a decimal held as an int64 count of 10^-scale units, where scale is usually taken from a
+// symbol's price or size increment. Arithmetic fails with ErrRange when a result leaves the int64
+// range. The book keeps decimal.Decimal; Number is not wired into it.
+type Number struct {
+	value int64
+	scale int32
+}
+
+// New returns value*10^-scale. It panics if scale is out of range, like RequireFromString.
+func New(value int64, scale int32) Number {
+	if scale < 0 || scale > MaxScale {
+		panic(fmt.Errorf("%w: %d", ErrScale, scale))
+	}
+	return Number{value: value, scale: scale}
+}
+
+// Scale returns the number of decimal places of an increment such as "0.001".
+func Scale(increment string) (scale int32, err error) {
+	var n Number
+	n, err = NewFromString(increment)
+	if err != nil {
+		return
+	}
+	scale = n.normalize().scale
+	return
+}
+
+// NewFromString parses s keeping every decimal place it carries, up to MaxScale; non-zero digits
+// beyond MaxScale fail with ErrPrecision.
+func NewFromString(s string) (n Number, err error) {
+	digits, frac, err := split(s)
+	if err != nil {
+		return
+	}
+	var scale = frac
+	if scale < 0 {
+		scale = 0
+	}
+	if scale > MaxScale {
+		scale = MaxScale
+	}
+	return parse(s, digits, frac, scale)
+}
+
+// Parse parses s at scale, failing with ErrPrecision if s has non-zero digits beyond it.
+func Parse(s string, scale int32) (n Number, err error) {
+	if scale < 0 || scale > MaxScale {
+		err = fmt.Errorf("%w: %d", ErrScale, scale)
+		return
+	}
+	digits, frac, err := split(s)
+	if err != nil {
+		return
+	}
+	return parse(s, digits, frac, scale)
+}
+
+func RequireFromString(s string, scale int32) Number {
+	n, err := Parse(s, scale)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// split returns the signed digits of s and how many of them sit after the decimal point, taking
+// an exponent into account.
+func split(s string) (digits string, frac int32, err error) {
+	var mantissa = s
+	var exp int64
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa = s[:i]
+		exp, err = strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			err = fmt.Errorf("%w: %q", ErrSyntax, s)
+			return
+		}
+	}
+	var sign string
+	if mantissa != "" && (mantissa[0] == '-' || mantissa[0] == '+') {
+		sign, mantissa = mantissa[:1], mantissa[1:]
+	}
+	var whole, fraction = mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		whole, fraction = mantissa[:i], mantissa[i+1:]
+	}
+	if whole == "" && fraction == "" {
+		err = fmt.Errorf("%w: %q", ErrSyntax, s)
+		return
+	}
+	for _, part := range []string{whole, fraction} {
+		for i := 0; i < len(part); i++ {
+			if part[i] < '0' || part[i] > '9' {
+				err = fmt.Errorf("%w: %q", ErrSyntax, s)
+				return
+			}
+		}
+	}
+	digits = sign + whole + fraction
+	frac = int32(int64(len(fraction)) - exp)
+	return
+}
+
+func parse(s, digits string, frac, scale int32) (n Number, err error) {
+	var negative bool
+	if digits[0] == '-' || digits[0] == '+' {
+		negative = digits[0] == '-'
+		digits = digits[1:]
+	}
+	var shift = scale - frac
+	if shift < 0 {
+		var drop = int(-shift)
+		if drop > len(digits) {
+			drop = len(digits)
+		}
+		if strings.Trim(digits[len(digits)-drop:], "0") != "" {
+			err = fmt.Errorf("%w: %q at scale %d", ErrPrecision, s, scale)
+			return
+		}
+		digits = digits[:len(digits)-drop]
+		shift = 0
+	}
+	var value uint64
+	for i := 0; i < len(digits); i++ {
+		var hi, lo = bits.Mul64(value, 10)
+		lo, carry := bits.Add64(lo, uint64(digits[i]-'0'), 0)
+		if hi != 0 || carry != 0 {
+			err = fmt.Errorf("%w: %q", ErrRange, s)
+			return
+		}
+		value = lo
+	}
+	if value != 0 && shift > MaxScale {
+		err = fmt.Errorf("%w: %q", ErrRange, s)
+		return
+	}
+	if value != 0 {
+		var hi, lo = bits.Mul64(value, uint64(pow10[shift]))
+		if hi != 0 {
+			err = fmt.Errorf("%w: %q", ErrRange, s)
+			return
+		}
+		value = lo
+	}
+	if value > math.MaxInt64 {
+		err = fmt.Errorf("%w: %q", ErrRange, s)
+		return
+	}
+	n = Number{value: int64(value), scale: scale}
+	if negative {
+		n.value = -n.value
+	}
+	return
+}
+
+// FromDecimal converts d to scale, failing with ErrPrecision if d has non-zero digits beyond it.
+func FromDecimal(d decimal.Decimal, scale int32) (n Number, err error) {
+	if scale < 0 || scale > MaxScale {
+		err = fmt.Errorf("%w: %d", ErrScale, scale)
+		return
+	}
+	var shifted = d.Shift(scale)
+	if !shifted.Equal(shifted.Truncate(0)) {
+		err = fmt.Errorf("%w: %s at scale %d", ErrPrecision, d, scale)
+		return
+	}
+	var i = shifted.Truncate(0).Coefficient()
+	if shifted.Exponent() > 0 {
+		i.Mul(i, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(shifted.Exponent())), nil))
+	}
+	if !i.IsInt64() {
+		err = fmt.Errorf("%w: %s", ErrRange, d)
+		return
+	}
+	n = Number{value: i.Int64(), scale: scale}
+	return
+}
+
+func (n Number) Decimal() decimal.Decimal {
+	return decimal.New(n.value, -n.scale)
+}
+
+func (n Number) Value() int64 {
+	return n.value
+}
+
+func (n Number) Scale() int32 {
+	return n.scale
+}
+
+func (n Number) normalize() Number {
+	for n.scale > 0 && n.value%10 == 0 {
+		n.value /= 10
+		n.scale--
+	}
+	return n
+}
+
+// Rescale returns n at scale, failing with ErrPrecision if that would drop non-zero digits.
+func (n Number) Rescale(scale int32) (r Number, err error) {
+	if scale < 0 || scale > MaxScale {
+		err = fmt.Errorf("%w: %d", ErrScale, scale)
+		return
+	}
+	if scale >= n.scale {
+		var value int64
+		value, err = mul(n.value, pow10[scale-n.scale])
+		r = Number{value: value, scale: scale}
+		return
+	}
+	var p = pow10[n.scale-scale]
+	if n.value%p != 0 {
+		err = fmt.Errorf("%w: %s at scale %d", ErrPrecision, n, scale)
+		return
+	}
+	r = Number{value: n.value / p, scale: scale}
+	return
+}
+
+// Truncate returns n at scale, dropping digits beyond it toward zero.
+func (n Number) Truncate(scale int32) (r Number, err error) {
+	if scale >= n.scale {
+		return n.Rescale(scale)
+	}
+	if scale < 0 {
+		err = fmt.Errorf("%w: %d", ErrScale, scale)
+		return
+	}
+	r = Number{value: n.value / pow10[n.scale-scale], scale: scale}
+	return
+}
+
+// Floor rounds n down to a multiple of step, which must be positive.
+func (n Number) Floor(step Number) (f Number, err error) {
+	a, b, err := align(n, step)
+	if err != nil {
+		return
+	}
+	if b.value <= 0 {
+		err = fmt.Errorf("%w: step %s", ErrRange, step)
+		return
+	}
+	var r = a.value % b.value
+	if r < 0 {
+		r += b.value
+	}
+	f = Number{value: a.value - r, scale: a.scale}
+	return
+}
+
+// Ceil rounds n up to a multiple of step, which must be positive.
+func (n Number) Ceil(step Number) (c Number, err error) {
+	c, err = n.Floor(step)
+	if err != nil || c.Equal(n) {
+		return
+	}
+	return c.Add(step)
+}
+
+func mul(a, b int64) (int64, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	var c = a * b
+	if c/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, ErrRange
+	}
+	return c, nil
+}
+
+// align rescales a and b to the larger of their scales.
+func align(a, b Number) (Number, Number, error) {
+	var err error
+	switch {
+	case a.scale < b.scale:
+		a, err = a.Rescale(b.scale)
+	case b.scale < a.scale:
+		b, err = b.Rescale(a.scale)
+	}
+	return a, b, err
+}
+
+func (n Number) Add(m Number) (r Number, err error) {
+	a, b, err := align(n, m)
+	if err != nil {
+		return
+	}
+	var c = a.value + b.value
+	if (c > a.value) != (b.value > 0) {
+		err = ErrRange
+		return
+	}
+	r = Number{value: c, scale: a.scale}
+	return
+}
+
+func (n Number) Sub(m Number) (r Number, err error) {
+	a, b, err := align(n, m)
+	if err != nil {
+		return
+	}
+	var c = a.value - b.value
+	if (c < a.value) != (b.value > 0) {
+		err = ErrRange
+		return
+	}
+	r = Number{value: c, scale: a.scale}
+	return
+}
+
+func (n Number) Neg() (r Number, err error) {
+	if n.value == math.MinInt64 {
+		err = ErrRange
+		return
+	}
+	r = Number{value: -n.value, scale: n.scale}
+	return
+}
+
+func (n Number) Abs() (Number, error) {
+	if n.value < 0 {
+		return n.Neg()
+	}
+	return n, nil
+}
+
+func (n Number) MulInt(i int64) (r Number, err error) {
+	var value int64
+	value, err = mul(n.value, i)
+	if err != nil {
+		return
+	}
+	r = Number{value: value, scale: n.scale}
+	return
+}
+
+func unsigned(i int64) (u uint64, negative bool) {
+	if i < 0 {
+		return uint64(-i), true
+	}
+	return uint64(i), false
+}
+
+func signed(u uint64, negative bool) (int64, error) {
+	if u > math.MaxInt64 {
+		return 0, ErrRange
+	}
+	if negative {
+		return -int64(u), nil
+	}
+	return int64(u), nil
+}
+
+// Mul returns n*m at the larger of the two scales, truncated toward zero.
+func (n Number) Mul(m Number) (p Number, err error) {
+	var scale, drop = n.scale, m.scale
+	if m.scale > scale {
+		scale, drop = m.scale, n.scale
+	}
+	var a, na = unsigned(n.value)
+	var b, nb = unsigned(m.value)
+	var hi, lo = bits.Mul64(a, b)
+	var d = uint64(pow10[drop])
+	if hi >= d {
+		err = ErrRange
+		return
+	}
+	var q, _ = bits.Div64(hi, lo, d)
+	var value int64
+	value, err = signed(q, na != nb)
+	if err != nil {
+		return
+	}
+	p = Number{value: value, scale: scale}
+	return
+}
+
+// Div returns n/m at the scale of n, truncated toward zero. It fails with ErrDivision when m is zero
+// and with ErrRange when the quotient leaves the int64 range.
+func (n Number) Div(m Number) (q Number, err error) {
+	if m.value == 0 {
+		err = ErrDivision
+		return
+	}
+	var a, na = unsigned(n.value)
+	var b, nb = unsigned(m.value)
+	var hi, lo = bits.Mul64(a, uint64(pow10[m.scale]))
+	if hi >= b {
+		err = ErrRange
+		return
+	}
+	var u, _ = bits.Div64(hi, lo, b)
+	var value int64
+	value, err = signed(u, na != nb)
+	if err != nil {
+		return
+	}
+	q = Number{value: value, scale: n.scale}
+	return
+}
+
+func (n Number) Cmp(m Number) int {
+	if n.scale == m.scale {
+		switch {
+		case n.value < m.value:
+			return -1
+		case n.value > m.value:
+			return 1
+		}
+		return 0
+	}
+	return n.Decimal().Cmp(m.Decimal())
+}
+
+func (n Number) Equal(m Number) bool { return n.Cmp(m) == 0 }
+
+func (n Number) LessThan(m Number) bool { return n.Cmp(m) < 0 }
+
+func (n Number) LessThanOrEqual(m Number) bool { return n.Cmp(m) <= 0 }
+
+func (n Number) GreaterThan(m Number) bool { return n.Cmp(m) > 0 }
+
+func (n Number) GreaterThanOrEqual(m Number) bool { return n.Cmp(m) >= 0 }
+
+func (n Number) Sign() int {
+	switch {
+	case n.value < 0:
+		return -1
+	case n.value > 0:
+		return 1
+	}
+	return 0
+}
+
+func (n Number) IsZero() bool { return n.value == 0 }
+
+func (n Number) String() string {
+	var u, negative = unsigned(n.value)
+	var s = strconv.FormatUint(u, 10)
+	if n.scale > 0 {
+		if len(s) <= int(n.scale) {
+			s = strings.Repeat("0", int(n.scale)-len(s)+1) + s
+		}
+		var i = len(s) - int(n.scale)
+		s = strings.TrimRight(s[:i]+"."+s[i:], "0")
+		s = strings.TrimSuffix(s, ".")
+	}
+	if negative {
+		s = "-" + s
+	}
+	return s
+}
+
+func (n Number) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + n.String() + `"`), nil
+}
+
+func (n *Number) UnmarshalJSON(b []byte) (err error) {
+	var s = string(b)
+	if s == "null" {
+		return
+	}
+	s = strings.Trim(s, `"`)
+	*n, err = NewFromString(s)
+	return
+}
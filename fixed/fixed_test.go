@@ -0,0 +1,172 @@
+package fixed
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestNewFromString(t *testing.T) {
+	tests := []struct {
+		s       string
+		value   int64
+		scale   int32
+		wantErr error
+	}{
+		{s: "0.001", value: 1, scale: 3},
+		{s: "-12.50", value: -1250, scale: 2},
+		{s: "1e3", value: 1000, scale: 0},
+		{s: "1.5e-2", value: 15, scale: 3},
+		{s: "0.000000000000000001", value: 1, scale: MaxScale},
+		{s: "1.0000000000000000000000", value: 1000000000000000000, scale: MaxScale},
+		{s: "0.0000000000000000000001", wantErr: ErrPrecision},
+		{s: "99999999999999999999", wantErr: ErrRange},
+		{s: "1.2.3", wantErr: ErrSyntax},
+		{s: "", wantErr: ErrSyntax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			n, err := NewFromString(tt.s)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (n.Value() != tt.value || n.Scale() != tt.scale) {
+				t.Fatalf("n = %d at %d, want %d at %d", n.Value(), n.Scale(), tt.value, tt.scale)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s       string
+		scale   int32
+		want    string
+		wantErr error
+	}{
+		{s: "1.5", scale: 4, want: "1.5"},
+		{s: "1.50000", scale: 2, want: "1.5"},
+		{s: "1.505", scale: 2, wantErr: ErrPrecision},
+		{s: "1", scale: MaxScale + 1, wantErr: ErrScale},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			n, err := Parse(tt.s, tt.scale)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && n.String() != tt.want {
+				t.Fatalf("n = %s, want %s", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	var n = func(s string) Number {
+		v, err := NewFromString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	var max, min = New(math.MaxInt64, 0), New(math.MinInt64, 0)
+	tests := []struct {
+		name    string
+		f       func() (Number, error)
+		want    string
+		wantErr error
+	}{
+		{name: "add", f: func() (Number, error) { return n("1.25").Add(n("0.005")) }, want: "1.255"},
+		{name: "sub", f: func() (Number, error) { return n("1").Sub(n("1.5")) }, want: "-0.5"},
+		{name: "mul", f: func() (Number, error) { return n("1.5").Mul(n("0.25")) }, want: "0.37"},
+		{name: "neg", f: func() (Number, error) { return n("-1.5").Neg() }, want: "1.5"},
+		{name: "floor", f: func() (Number, error) { return n("10.37").Floor(n("0.05")) }, want: "10.35"},
+		{name: "floor negative", f: func() (Number, error) { return n("-10.37").Floor(n("0.05")) }, want: "-10.4"},
+		{name: "ceil", f: func() (Number, error) { return n("10.31").Ceil(n("0.05")) }, want: "10.35"},
+		{name: "truncate", f: func() (Number, error) { return n("-1.239").Truncate(2) }, want: "-1.23"},
+		{name: "add overflow", f: func() (Number, error) { return max.Add(n("1")) }, wantErr: ErrRange},
+		{name: "add rescale overflow", f: func() (Number, error) { return max.Add(n("0.1")) }, wantErr: ErrRange},
+		{name: "sub overflow", f: func() (Number, error) { return min.Sub(n("1")) }, wantErr: ErrRange},
+		{name: "sub min", f: func() (Number, error) { return n("-1").Sub(min) }, want: "9223372036854775807"},
+		{name: "mul overflow", f: func() (Number, error) { return max.Mul(n("2")) }, wantErr: ErrRange},
+		{name: "neg overflow", f: func() (Number, error) { return min.Neg() }, wantErr: ErrRange},
+		{name: "floor zero step", f: func() (Number, error) { return n("1").Floor(n("0")) }, wantErr: ErrRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.f()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiv(t *testing.T) {
+	tests := []struct {
+		n, m    string
+		want    string
+		wantErr error
+	}{
+		{n: "10.00", m: "3", want: "3.33"},
+		{n: "-1.000", m: "0.3", want: "-3.333"},
+		{n: "1", m: "0", wantErr: ErrDivision},
+		{n: "9000000000000000000", m: "0.1", wantErr: ErrRange},
+		{n: "1", m: "0.0000000000000000000000", wantErr: ErrDivision},
+	}
+	for _, tt := range tests {
+		t.Run(tt.n+"/"+tt.m, func(t *testing.T) {
+			n, err := NewFromString(tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := NewFromString(tt.m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q, err := n.Div(m)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && q.String() != tt.want {
+				t.Fatalf("q = %s, want %s", q, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    string
+		wantErr error
+	}{
+		{data: `"0.0015"`, want: "0.0015"},
+		{data: `"1.0000000000000000000000"`, want: "1"},
+		{data: `"0.0000000000000000000001"`, wantErr: ErrPrecision},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			var n Number
+			err := json.Unmarshal([]byte(tt.data), &n)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			b, err := json.Marshal(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != `"`+tt.want+`"` {
+				t.Fatalf("json = %s, want %q", b, tt.want)
+			}
+		})
+	}
+}
@@ -3,9 +3,9 @@ module github.com/bzeron/mk
 go 1.14
 
 require (
-	github.com/emirpasic/gods v1.12.0
 	github.com/gorilla/websocket v1.4.2
-	github.com/joho/godotenv v1.3.0 // indirect
+	github.com/joho/godotenv v1.3.0
 	github.com/satori/go.uuid v1.2.0
 	github.com/shopspring/decimal v0.0.0-20200227202807-02e2044944cc
+	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
 )
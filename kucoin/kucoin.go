@@ -55,12 +55,20 @@ func WithDefaultEndpoint(endpoint string) Option {
 	}
 }
 
+func WithRestTap(tap Tap) Option {
+	return func(client *Client) (err error) {
+		client.tap = tap
+		return
+	}
+}
+
 type Client struct {
 	endpoint   *url.URL
 	key        string
 	secret     string
 	passphrase string
 	sign       *sign
+	tap        Tap
 }
 
 func NewClient(options ...Option) (client *Client, err error) {
@@ -105,6 +113,14 @@ func (c *Client) Send(call *CallRequest) (buf *bytes.Buffer, err error) {
 		err = fmt.Errorf("api error: [code:%s, message:%s]", resp.Code, resp.Message)
 		return
 	}
+	if c.tap != nil {
+		c.tap(Frame{
+			Type:  RecorderRest,
+			Topic: call.url.RequestURI(),
+			Time:  time.Now().UnixNano(),
+			Data:  resp.Data,
+		})
+	}
 	buf = bytes.NewBuffer(resp.Data)
 	return
 }
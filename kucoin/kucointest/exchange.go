@@ -0,0 +1,417 @@
+package kucointest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ExchangeTimestampWindow = 5 * time.Second
+)
+
+const (
+	CodeTimestampInvalid  = "400002"
+	CodeKeyNotExists      = "400003"
+	CodePassphraseInvalid = "400004"
+	CodeSignatureInvalid  = "400005"
+	CodeParamsInvalid     = "400100"
+	CodeOrderNotExists    = "404000"
+	CodeTooManyRequests   = "429000"
+)
+
+type (
+	Order struct {
+		Id          string          `json:"id"`
+		ClientOid   string          `json:"clientOid"`
+		Symbol      string          `json:"symbol"`
+		Type        string          `json:"type"`
+		Side        string          `json:"side"`
+		Price       decimal.Decimal `json:"price"`
+		Size        decimal.Decimal `json:"size"`
+		DealSize    decimal.Decimal `json:"dealSize"`
+		DealFunds   decimal.Decimal `json:"dealFunds"`
+		TimeInForce string          `json:"timeInForce"`
+		IsActive    bool            `json:"isActive"`
+		CancelExist bool            `json:"cancelExist"`
+		CreatedAt   int64           `json:"createdAt"`
+	}
+
+	Account struct {
+		Id        string          `json:"id"`
+		Currency  string          `json:"currency"`
+		Type      string          `json:"type"`
+		Balance   decimal.Decimal `json:"balance"`
+		Available decimal.Decimal `json:"available"`
+		Holds     decimal.Decimal `json:"holds"`
+	}
+
+	fault struct {
+		code  string
+		msg   string
+		times int
+	}
+
+	Exchange struct {
+		m          sync.Mutex
+		key        string
+		secret     string
+		passphrase string
+		sequence   int64
+		orders     map[string]*Order
+		accounts   []*Account
+		market     map[string]interface{}
+		faults     map[string]*fault
+		latency    time.Duration
+	}
+
+	text string
+)
+
+func newExchange() *Exchange {
+	return &Exchange{
+		orders: make(map[string]*Order),
+		market: make(map[string]interface{}),
+		faults: make(map[string]*fault),
+	}
+}
+
+func (server *Server) exchange() {
+	server.Exchange = newExchange()
+	server.mux.HandleFunc("/api/v1/orders", server.private(server.ordersHandler))
+	server.mux.HandleFunc("/api/v1/orders/", server.private(server.orderHandler))
+	server.mux.HandleFunc("/api/v1/accounts", server.private(server.accountsHandler))
+	server.mux.HandleFunc("/api/v1/bullet-private", server.private(server.bullet))
+	server.mux.HandleFunc("/api/v1/market/", server.public(server.marketHandler))
+	server.mux.HandleFunc("/api/v3/market/", server.public(server.marketHandler))
+}
+
+func (exchange *Exchange) SetCredentials(key, secret, passphrase string) {
+	exchange.m.Lock()
+	defer exchange.m.Unlock()
+	exchange.key = key
+	exchange.secret = secret
+	exchange.passphrase = passphrase
+}
+
+func (exchange *Exchange) SetLatency(latency time.Duration) {
+	exchange.m.Lock()
+	defer exchange.m.Unlock()
+	exchange.latency = latency
+}
+
+func (exchange *Exchange) Inject(method, path, code, msg string, times int) {
+	exchange.m.Lock()
+	defer exchange.m.Unlock()
+	exchange.faults[method+" "+path] = &fault{code: code, msg: msg, times: times}
+}
+
+func (exchange *Exchange) SetAccount(account Account) {
+	exchange.m.Lock()
+	defer exchange.m.Unlock()
+	for i, v := range exchange.accounts {
+		if v.Currency == account.Currency && v.Type == account.Type {
+			exchange.accounts[i] = &account
+			return
+		}
+	}
+	if account.Id == "" {
+		account.Id = exchange.nextId()
+	}
+	exchange.accounts = append(exchange.accounts, &account)
+}
+
+func (exchange *Exchange) SetMarket(path, symbol string, data interface{}) {
+	exchange.m.Lock()
+	defer exchange.m.Unlock()
+	exchange.market[path+"?"+symbol] = data
+}
+
+func (exchange *Exchange) Orders() (orders []Order) {
+	exchange.m.Lock()
+	defer exchange.m.Unlock()
+	for _, order := range exchange.orders {
+		orders = append(orders, *order)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].Id < orders[j].Id })
+	return
+}
+
+func (exchange *Exchange) Fill(orderId string, size decimal.Decimal) (ok bool) {
+	exchange.m.Lock()
+	defer exchange.m.Unlock()
+	order, ok := exchange.orders[orderId]
+	if !ok || !order.IsActive {
+		return false
+	}
+	remain := order.Size.Sub(order.DealSize)
+	if size.GreaterThan(remain) {
+		size = remain
+	}
+	order.DealSize = order.DealSize.Add(size)
+	order.DealFunds = order.DealFunds.Add(size.Mul(order.Price))
+	order.IsActive = order.DealSize.LessThan(order.Size)
+	return
+}
+
+func (exchange *Exchange) nextId() string {
+	exchange.sequence++
+	return strconv.FormatInt(time.Now().Unix(), 16) + strconv.FormatInt(exchange.sequence, 16)
+}
+
+func (exchange *Exchange) delay(r *http.Request) (f *fault) {
+	exchange.m.Lock()
+	var latency = exchange.latency
+	f, ok := exchange.faults[r.Method+" "+r.URL.Path]
+	if ok {
+		f.times--
+		if f.times <= 0 {
+			delete(exchange.faults, r.Method+" "+r.URL.Path)
+		}
+	}
+	exchange.m.Unlock()
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	return
+}
+
+func (exchange *Exchange) public(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if f := exchange.delay(r); f != nil {
+			ReplyError(w, f.code, f.msg, nil)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (exchange *Exchange) private(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if f := exchange.delay(r); f != nil {
+			ReplyError(w, f.code, f.msg, nil)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		code, msg := exchange.verify(r, body)
+		if code != "" {
+			ReplyStatus(w, http.StatusUnauthorized, code, msg, nil)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (exchange *Exchange) verify(r *http.Request, body []byte) (code, msg string) {
+	exchange.m.Lock()
+	var key, secret, passphrase = exchange.key, exchange.secret, exchange.passphrase
+	exchange.m.Unlock()
+	timestamp, err := strconv.ParseInt(r.Header.Get("KC-API-TIMESTAMP"), 10, 64)
+	if err != nil {
+		return CodeTimestampInvalid, "KC-API-TIMESTAMP Invalid"
+	}
+	if d := time.Since(time.Unix(0, timestamp*int64(time.Millisecond))); d > ExchangeTimestampWindow || d < -ExchangeTimestampWindow {
+		return CodeTimestampInvalid, "KC-API-TIMESTAMP Invalid"
+	}
+	if r.Header.Get("KC-API-KEY") != key {
+		return CodeKeyNotExists, "KC-API-KEY not exists"
+	}
+	if r.Header.Get("KC-API-PASSPHRASE") != passphrase {
+		return CodePassphraseInvalid, "KC-API-PASSPHRASE error"
+	}
+	hm := hmac.New(sha256.New, []byte(secret))
+	_, _ = hm.Write([]byte(r.Header.Get("KC-API-TIMESTAMP") + r.Method + r.URL.RequestURI()))
+	_, _ = hm.Write(body)
+	sign, err := base64.StdEncoding.DecodeString(r.Header.Get("KC-API-SIGN"))
+	if err != nil || !hmac.Equal(sign, hm.Sum(nil)) {
+		return CodeSignatureInvalid, "Signature error"
+	}
+	return
+}
+
+func (exchange *Exchange) ordersHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		exchange.place(w, r)
+	case http.MethodGet:
+		exchange.list(w, r)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (exchange *Exchange) place(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ClientOid   text `json:"clientOid"`
+		Symbol      text `json:"symbol"`
+		Side        text `json:"side"`
+		Type        text `json:"type"`
+		Price       text `json:"price"`
+		Size        text `json:"size"`
+		TimeInForce text `json:"timeInForce"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ReplyError(w, CodeParamsInvalid, err.Error(), nil)
+		return
+	}
+	if req.ClientOid == "" || req.Symbol == "" || (req.Side != "buy" && req.Side != "sell") {
+		ReplyError(w, CodeParamsInvalid, "Parameter error", nil)
+		return
+	}
+	var order = &Order{
+		ClientOid:   string(req.ClientOid),
+		Symbol:      string(req.Symbol),
+		Side:        string(req.Side),
+		Type:        string(req.Type),
+		TimeInForce: string(req.TimeInForce),
+		IsActive:    true,
+		CreatedAt:   time.Now().UnixNano() / 1e6,
+	}
+	if order.Type == "" {
+		order.Type = "limit"
+	}
+	if order.TimeInForce == "" {
+		order.TimeInForce = "GTC"
+	}
+	// market orders take no price.
+	if order.Type != "market" || req.Price != "" {
+		order.Price, err = decimal.NewFromString(string(req.Price))
+	}
+	if err == nil {
+		order.Size, err = decimal.NewFromString(string(req.Size))
+	}
+	if err != nil || !order.Size.IsPositive() || (order.Type != "market" && !order.Price.IsPositive()) {
+		ReplyError(w, CodeParamsInvalid, "Parameter error", nil)
+		return
+	}
+	exchange.m.Lock()
+	order.Id = exchange.nextId()
+	exchange.orders[order.Id] = order
+	exchange.m.Unlock()
+	Reply(w, map[string]string{"orderId": order.Id})
+}
+
+func (exchange *Exchange) list(w http.ResponseWriter, r *http.Request) {
+	var query = r.URL.Query()
+	var items = make([]Order, 0)
+	for _, order := range exchange.Orders() {
+		if symbol := query.Get("symbol"); symbol != "" && order.Symbol != symbol {
+			continue
+		}
+		if side := query.Get("side"); side != "" && order.Side != side {
+			continue
+		}
+		if status := query.Get("status"); (status == "active" && !order.IsActive) || (status == "done" && order.IsActive) {
+			continue
+		}
+		items = append(items, order)
+	}
+	currentPage, _ := strconv.Atoi(query.Get("currentPage"))
+	pageSize, _ := strconv.Atoi(query.Get("pageSize"))
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 {
+		pageSize = 50
+	}
+	var total = len(items)
+	var start, end = (currentPage - 1) * pageSize, currentPage * pageSize
+	if start > total {
+		start = total
+	}
+	if end > total {
+		end = total
+	}
+	Reply(w, map[string]interface{}{
+		"currentPage": currentPage,
+		"pageSize":    pageSize,
+		"totalNum":    total,
+		"totalPage":   (total + pageSize - 1) / pageSize,
+		"items":       items[start:end],
+	})
+}
+
+func (exchange *Exchange) orderHandler(w http.ResponseWriter, r *http.Request) {
+	var id = strings.TrimPrefix(r.URL.Path, "/api/v1/orders/")
+	exchange.m.Lock()
+	order, ok := exchange.orders[id]
+	var snapshot Order
+	if ok {
+		if r.Method == http.MethodDelete && order.IsActive {
+			order.IsActive = false
+			order.CancelExist = true
+		} else if r.Method == http.MethodDelete {
+			ok = false
+		}
+		snapshot = *order
+	}
+	exchange.m.Unlock()
+	if !ok {
+		ReplyError(w, CodeOrderNotExists, "order_not_exist_or_not_allow_to_cancel", nil)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		Reply(w, snapshot)
+	case http.MethodDelete:
+		Reply(w, map[string][]string{"cancelledOrderIds": {id}})
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (exchange *Exchange) accountsHandler(w http.ResponseWriter, r *http.Request) {
+	var query = r.URL.Query()
+	var accounts = make([]Account, 0)
+	exchange.m.Lock()
+	for _, account := range exchange.accounts {
+		if currency := query.Get("currency"); currency != "" && account.Currency != currency {
+			continue
+		}
+		if t := query.Get("type"); t != "" && account.Type != t {
+			continue
+		}
+		accounts = append(accounts, *account)
+	}
+	exchange.m.Unlock()
+	Reply(w, accounts)
+}
+
+func (exchange *Exchange) marketHandler(w http.ResponseWriter, r *http.Request) {
+	exchange.m.Lock()
+	data, ok := exchange.market[r.URL.Path+"?"+r.URL.Query().Get("symbol")]
+	exchange.m.Unlock()
+	if !ok {
+		ReplyError(w, CodeParamsInvalid, "symbol not exists", nil)
+		return
+	}
+	Reply(w, data)
+}
+
+func (t *text) UnmarshalJSON(b []byte) (err error) {
+	var s string
+	if len(b) > 0 && b[0] == '"' {
+		err = json.Unmarshal(b, &s)
+	} else {
+		s = string(b)
+	}
+	*t = text(s)
+	return
+}
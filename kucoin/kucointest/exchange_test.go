@@ -0,0 +1,158 @@
+package kucointest_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bzeron/mk/kucoin"
+	"github.com/bzeron/mk/kucoin/kucointest"
+)
+
+func client(t *testing.T, server *kucointest.Server) *kucoin.Client {
+	server.SetCredentials("key", "secret", "passphrase")
+	client, err := server.Client(kucoin.WithAuth("key", "secret", "passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func send(client *kucoin.Client, method, endpoint string, body interface{}) (err error) {
+	call, err := client.NewCallRequest(method, endpoint, nil, nil, body)
+	if err != nil {
+		return
+	}
+	_, err = client.Send(call)
+	return
+}
+
+func TestPlace(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    map[string]string
+		wantCode string
+	}{
+		{name: "limit", order: map[string]string{"clientOid": "1", "symbol": "BTC-USDT", "side": "buy", "price": "100", "size": "1"}},
+		{name: "market without price", order: map[string]string{"clientOid": "2", "symbol": "BTC-USDT", "side": "sell", "type": "market", "size": "1"}},
+		{name: "limit without price", order: map[string]string{"clientOid": "3", "symbol": "BTC-USDT", "side": "buy", "size": "1"}, wantCode: kucointest.CodeParamsInvalid},
+		{name: "invalid side", order: map[string]string{"clientOid": "4", "symbol": "BTC-USDT", "side": "hold", "price": "100", "size": "1"}, wantCode: kucointest.CodeParamsInvalid},
+		{name: "zero size", order: map[string]string{"clientOid": "5", "symbol": "BTC-USDT", "side": "buy", "price": "100", "size": "0"}, wantCode: kucointest.CodeParamsInvalid},
+	}
+	var server = kucointest.NewServer()
+	defer server.Close()
+	var c = client(t, server)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := send(c, http.MethodPost, "/api/v1/orders", tt.order)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "code:"+tt.wantCode) {
+				t.Fatalf("err = %v, want code %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestCancelUnknownOrder(t *testing.T) {
+	var server = kucointest.NewServer()
+	defer server.Close()
+	err := send(client(t, server), http.MethodDelete, "/api/v1/orders/unknown", nil)
+	if err == nil || !strings.Contains(err.Error(), "code:"+kucointest.CodeOrderNotExists) {
+		t.Fatalf("err = %v, want code %s", err, kucointest.CodeOrderNotExists)
+	}
+}
+
+// signed sends a request signed the way kucoin.Client does with secret, after mutate changed its
+// headers, and returns the http status and KuCoin code of the response.
+func signed(t *testing.T, server *kucointest.Server, secret string, mutate func(header http.Header)) (status int, code string) {
+	const method, path, body = http.MethodGet, "/api/v1/accounts", ""
+	request, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var timestamp = strconv.FormatInt(time.Now().UnixNano()/1e6, 10)
+	var hm = hmac.New(sha256.New, []byte(secret))
+	_, _ = hm.Write([]byte(timestamp + method + path + body))
+	request.Header.Set("KC-API-KEY", "key")
+	request.Header.Set("KC-API-PASSPHRASE", "passphrase")
+	request.Header.Set("KC-API-TIMESTAMP", timestamp)
+	request.Header.Set("KC-API-SIGN", base64.StdEncoding.EncodeToString(hm.Sum(nil)))
+	if mutate != nil {
+		mutate(request.Header)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = response.Body.Close() }()
+	if got := response.Header.Get("Content-Type"); got != "application/json" {
+		t.Fatalf("content type = %q, want application/json", got)
+	}
+	var reply struct {
+		Code string `json:"code"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.StatusCode, reply.Code
+}
+
+func TestAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		secret     string
+		mutate     func(header http.Header)
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "valid", secret: "secret", wantStatus: http.StatusOK, wantCode: kucoin.ApiResponseSuccess},
+		{name: "bad signature", secret: "other", wantStatus: http.StatusUnauthorized, wantCode: kucointest.CodeSignatureInvalid},
+		{
+			name:   "stale timestamp",
+			secret: "secret",
+			mutate: func(header http.Header) {
+				var stale = time.Now().Add(-2*kucointest.ExchangeTimestampWindow).UnixNano() / 1e6
+				header.Set("KC-API-TIMESTAMP", strconv.FormatInt(stale, 10))
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   kucointest.CodeTimestampInvalid,
+		},
+		{
+			name:       "wrong passphrase",
+			secret:     "secret",
+			mutate:     func(header http.Header) { header.Set("KC-API-PASSPHRASE", "other") },
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   kucointest.CodePassphraseInvalid,
+		},
+		{
+			name:       "unknown key",
+			secret:     "secret",
+			mutate:     func(header http.Header) { header.Set("KC-API-KEY", "other") },
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   kucointest.CodeKeyNotExists,
+		},
+	}
+	var server = kucointest.NewServer()
+	defer server.Close()
+	server.SetCredentials("key", "secret", "passphrase")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, code := signed(t, server, tt.secret, tt.mutate)
+			if status != tt.wantStatus || code != tt.wantCode {
+				t.Fatalf("status = %d, code = %s, want %d and %s", status, code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
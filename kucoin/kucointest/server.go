@@ -0,0 +1,384 @@
+package kucointest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bzeron/mk/kucoin"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	ServerPingInterval = int64(18000)
+	ServerPingTimeout  = int64(10000)
+)
+
+type (
+	Message struct {
+		Type     string      `json:"type"`
+		Topic    string      `json:"topic,omitempty"`
+		Subject  string      `json:"subject,omitempty"`
+		TunnelId string      `json:"tunnelId,omitempty"`
+		Data     interface{} `json:"data,omitempty"`
+	}
+
+	request struct {
+		Id             string `json:"id"`
+		Type           string `json:"type"`
+		Topic          string `json:"topic"`
+		NewTunnelId    string `json:"newTunnelId"`
+		CloseTunnel    string `json:"closeTunnel"`
+		TunnelId       string `json:"tunnelId"`
+		PrivateChannel bool   `json:"privateChannel"`
+		Response       bool   `json:"response"`
+	}
+
+	route struct {
+		tunnelId string
+		topic    string
+	}
+
+	Conn struct {
+		m      sync.Mutex
+		id     string
+		server *Server
+		ws     *websocket.Conn
+		routes map[route]struct{}
+	}
+
+	Server struct {
+		*httptest.Server
+		*Exchange
+		m            sync.Mutex
+		mux          *http.ServeMux
+		upgrader     websocket.Upgrader
+		conns        map[*Conn]struct{}
+		scripts      map[string][]Message
+		subscribed   *sync.Cond
+		pingInterval int64
+		pingTimeout  int64
+		dropPongs    bool
+		ackDelay     time.Duration
+		welcome      interface{}
+		requests     []string
+	}
+)
+
+func NewServer() (server *Server) {
+	server = &Server{
+		mux:          http.NewServeMux(),
+		conns:        make(map[*Conn]struct{}),
+		scripts:      make(map[string][]Message),
+		pingInterval: ServerPingInterval,
+		pingTimeout:  ServerPingTimeout,
+	}
+	server.subscribed = sync.NewCond(&server.m)
+	server.mux.HandleFunc("/api/v1/bullet-public", server.bullet)
+	server.mux.HandleFunc("/endpoint", server.websocket)
+	server.exchange()
+	server.Server = httptest.NewServer(server.mux)
+	return
+}
+
+func (server *Server) Client(options ...kucoin.Option) (client *kucoin.Client, err error) {
+	options = append([]kucoin.Option{kucoin.WithEndpoint(server.URL)}, options...)
+	client, err = kucoin.NewClient(options...)
+	return
+}
+
+func (server *Server) Token() kucoin.Token {
+	server.m.Lock()
+	defer server.m.Unlock()
+	return kucoin.Token{
+		Token: "kucointest",
+		InstanceServers: []kucoin.InstanceServer{{
+			Endpoint:     "ws" + strings.TrimPrefix(server.URL, "http") + "/endpoint",
+			Protocol:     "websocket",
+			PingInterval: server.pingInterval,
+			PingTimeout:  server.pingTimeout,
+		}},
+	}
+}
+
+func (server *Server) Handle(pattern string, handler http.HandlerFunc) {
+	server.mux.HandleFunc(pattern, handler)
+}
+
+func (server *Server) SetPing(interval, timeout time.Duration) {
+	server.m.Lock()
+	defer server.m.Unlock()
+	server.pingInterval = int64(interval / time.Millisecond)
+	server.pingTimeout = int64(timeout / time.Millisecond)
+}
+
+func (server *Server) DropPongs(drop bool) {
+	server.m.Lock()
+	defer server.m.Unlock()
+	server.dropPongs = drop
+}
+
+func (server *Server) DelayAcks(delay time.Duration) {
+	server.m.Lock()
+	defer server.m.Unlock()
+	server.ackDelay = delay
+}
+
+func (server *Server) RejectWelcome(code int, msg string) {
+	server.m.Lock()
+	defer server.m.Unlock()
+	server.welcome = map[string]interface{}{"type": kucoin.WebsocketError, "code": code, "data": msg}
+}
+
+func (server *Server) Script(topic string, messages ...Message) {
+	server.m.Lock()
+	defer server.m.Unlock()
+	server.scripts[topic] = append(server.scripts[topic], messages...)
+}
+
+func (server *Server) Push(topic, subject string, data interface{}) (n int) {
+	n = server.PushTunnel("", topic, subject, data)
+	return
+}
+
+func (server *Server) PushTunnel(tunnelId, topic, subject string, data interface{}) (n int) {
+	var msg = Message{Type: kucoin.WebsocketMessage, Topic: topic, Subject: subject, TunnelId: tunnelId, Data: data}
+	for _, conn := range server.Conns() {
+		if conn.Subscribed(tunnelId, topic) && conn.Send(msg) == nil {
+			n++
+		}
+	}
+	return
+}
+
+func (server *Server) Conns() (conns []*Conn) {
+	server.m.Lock()
+	defer server.m.Unlock()
+	conns = make([]*Conn, 0, len(server.conns))
+	for conn := range server.conns {
+		conns = append(conns, conn)
+	}
+	return
+}
+
+func (server *Server) Requests() (requests []string) {
+	server.m.Lock()
+	defer server.m.Unlock()
+	requests = append(requests, server.requests...)
+	return
+}
+
+func (server *Server) Disconnect() {
+	for _, conn := range server.Conns() {
+		_ = conn.ws.Close()
+	}
+}
+
+func (server *Server) WaitSubscribed(topic string, n int, timeout time.Duration) (err error) {
+	var timer = time.AfterFunc(timeout, func() {
+		server.m.Lock()
+		defer server.m.Unlock()
+		server.subscribed.Broadcast()
+	})
+	defer timer.Stop()
+	var deadline = time.Now().Add(timeout)
+	server.m.Lock()
+	defer server.m.Unlock()
+	for server.count(topic) < n {
+		if time.Now().After(deadline) {
+			err = fmt.Errorf("kucointest waited subscribe: %s, timeout: %s", topic, timeout)
+			return
+		}
+		server.subscribed.Wait()
+	}
+	return
+}
+
+func (server *Server) count(topic string) (n int) {
+	for conn := range server.conns {
+		conn.m.Lock()
+		for r := range conn.routes {
+			if r.topic == topic {
+				n++
+			}
+		}
+		conn.m.Unlock()
+	}
+	return
+}
+
+func (server *Server) bullet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	Reply(w, server.Token())
+}
+
+func (server *Server) websocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := server.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	var conn = &Conn{
+		id:     r.URL.Query().Get("connectId"),
+		server: server,
+		ws:     ws,
+		routes: make(map[route]struct{}),
+	}
+	server.m.Lock()
+	var welcome = server.welcome
+	server.m.Unlock()
+	if welcome != nil {
+		_ = conn.Send(welcome)
+		_ = ws.Close()
+		return
+	}
+	err = conn.Send(map[string]string{"id": conn.id, "type": kucoin.WebsocketWelcome})
+	if err != nil {
+		_ = ws.Close()
+		return
+	}
+	server.m.Lock()
+	server.conns[conn] = struct{}{}
+	server.m.Unlock()
+	conn.serve()
+	server.m.Lock()
+	delete(server.conns, conn)
+	server.subscribed.Broadcast()
+	server.m.Unlock()
+}
+
+func (conn *Conn) Id() string {
+	return conn.id
+}
+
+func (conn *Conn) Subscribed(tunnelId, topic string) (ok bool) {
+	conn.m.Lock()
+	defer conn.m.Unlock()
+	_, ok = conn.routes[route{tunnelId: tunnelId, topic: topic}]
+	return
+}
+
+func (conn *Conn) Send(v interface{}) (err error) {
+	conn.m.Lock()
+	defer conn.m.Unlock()
+	err = conn.ws.WriteJSON(v)
+	return
+}
+
+func (conn *Conn) Close() (err error) {
+	err = conn.ws.Close()
+	return
+}
+
+func (conn *Conn) serve() {
+	defer func() { _ = conn.ws.Close() }()
+	for {
+		var req request
+		err := conn.ws.ReadJSON(&req)
+		if err != nil {
+			return
+		}
+		conn.server.m.Lock()
+		conn.server.requests = append(conn.server.requests, req.Type)
+		var dropPongs, delay = conn.server.dropPongs, conn.server.ackDelay
+		conn.server.m.Unlock()
+		switch req.Type {
+		case kucoin.WebsocketPing:
+			if !dropPongs {
+				_ = conn.Send(map[string]string{"id": req.Id, "type": kucoin.WebsocketPong})
+			}
+			continue
+		case kucoin.WebsocketMessageSubscribe:
+			conn.route(route{tunnelId: req.TunnelId, topic: req.Topic}, true)
+		case kucoin.WebsocketMessageUnsubscribe:
+			conn.route(route{tunnelId: req.TunnelId, topic: req.Topic}, false)
+		case kucoin.WebsocketMessageOpenTunnel:
+		case kucoin.WebsocketMessageCloseTunnel:
+			conn.closeTunnel(req.CloseTunnel)
+		default:
+			_ = conn.Send(map[string]interface{}{"id": req.Id, "type": kucoin.WebsocketError, "code": 400, "data": "invalid request type: " + req.Type})
+			continue
+		}
+		go conn.ack(req, delay)
+	}
+}
+
+func (conn *Conn) route(r route, subscribe bool) {
+	conn.m.Lock()
+	if subscribe {
+		conn.routes[r] = struct{}{}
+	} else {
+		delete(conn.routes, r)
+	}
+	conn.m.Unlock()
+	conn.server.m.Lock()
+	conn.server.subscribed.Broadcast()
+	conn.server.m.Unlock()
+}
+
+// closeTunnel drops the routes of tunnelId.
+func (conn *Conn) closeTunnel(tunnelId string) {
+	conn.m.Lock()
+	for r := range conn.routes {
+		if r.tunnelId == tunnelId {
+			delete(conn.routes, r)
+		}
+	}
+	conn.m.Unlock()
+}
+
+func (conn *Conn) ack(req request, delay time.Duration) {
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	if req.Response {
+		_ = conn.Send(map[string]string{"id": req.Id, "type": kucoin.WebsocketAck})
+	}
+	if req.Type != kucoin.WebsocketMessageSubscribe {
+		return
+	}
+	conn.server.m.Lock()
+	var script = conn.server.scripts[req.Topic]
+	conn.server.m.Unlock()
+	for _, msg := range script {
+		if msg.Type == "" {
+			msg.Type = kucoin.WebsocketMessage
+		}
+		if msg.Topic == "" {
+			msg.Topic = req.Topic
+		}
+		if msg.TunnelId == "" {
+			msg.TunnelId = req.TunnelId
+		}
+		if conn.Send(msg) != nil {
+			return
+		}
+	}
+}
+
+func Reply(w http.ResponseWriter, data interface{}) {
+	ReplyError(w, kucoin.ApiResponseSuccess, "", data)
+}
+
+func ReplyError(w http.ResponseWriter, code, msg string, data interface{}) {
+	ReplyStatus(w, http.StatusOK, code, msg, data)
+}
+
+// ReplyStatus writes a KuCoin response with the http status; the content type is set before the
+// status is written.
+func ReplyStatus(w http.ResponseWriter, status int, code, msg string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	var b, _ = json.Marshal(data)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"code": code,
+		"msg":  msg,
+		"data": json.RawMessage(b),
+	})
+}
@@ -0,0 +1,217 @@
+package kucoin
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	RecorderBufferSize    = 1 << 12
+	RecorderMaxSize       = int64(1 << 28)
+	RecorderMaxAge        = time.Hour
+	RecorderFlushInterval = time.Second
+)
+
+const (
+	RecorderExt  = ".jsonl.gz"
+	RecorderRest = "rest"
+)
+
+type (
+	Frame struct {
+		Type     string          `json:"type"`
+		Topic    string          `json:"topic"`
+		Subject  string          `json:"subject,omitempty"`
+		TunnelId string          `json:"tunnelId,omitempty"`
+		Time     int64           `json:"time"`
+		Data     json.RawMessage `json:"data"`
+	}
+
+	Tap func(frame Frame)
+
+	// counter counts the bytes written through it.
+	counter struct {
+		w io.Writer
+		n int64
+	}
+
+	// Recorder writes frames into gzipped jsonl files, starting a new file once the current one
+	// holds maxSize compressed bytes or is older than maxAge. The first error stops recording;
+	// later frames are counted as dropped and Close returns the error.
+	Recorder struct {
+		dir     string
+		prefix  string
+		maxSize int64
+		maxAge  time.Duration
+		frames  chan Frame
+		dropped uint64
+		m       sync.RWMutex
+		closed  bool
+		err     error
+		wg      sync.WaitGroup
+		once    sync.Once
+
+		file    *os.File
+		zip     *gzip.Writer
+		buf     *bufio.Writer
+		written *counter
+		opened  time.Time
+	}
+)
+
+func (c *counter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	c.n += int64(n)
+	return
+}
+
+func WithTap(tap Tap) WebsocketOption {
+	return func(conn *WebsocketConn) (err error) {
+		conn.tap = tap
+		return
+	}
+}
+
+func NewRecorder(dir, prefix string) (recorder *Recorder, err error) {
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return
+	}
+	recorder = &Recorder{
+		dir:     dir,
+		prefix:  prefix,
+		maxSize: RecorderMaxSize,
+		maxAge:  RecorderMaxAge,
+		frames:  make(chan Frame, RecorderBufferSize),
+	}
+	err = recorder.rotate()
+	if err != nil {
+		return
+	}
+	recorder.wg.Add(1)
+	go recorder.run()
+	return
+}
+
+func (recorder *Recorder) Record(frame Frame) {
+	recorder.m.RLock()
+	defer recorder.m.RUnlock()
+	if recorder.closed {
+		return
+	}
+	select {
+	case recorder.frames <- frame:
+	default:
+		atomic.AddUint64(&recorder.dropped, 1)
+	}
+}
+
+func (recorder *Recorder) Dropped() uint64 {
+	return atomic.LoadUint64(&recorder.dropped)
+}
+
+func (recorder *Recorder) Close() (err error) {
+	recorder.once.Do(func() {
+		recorder.m.Lock()
+		recorder.closed = true
+		close(recorder.frames)
+		recorder.m.Unlock()
+		recorder.wg.Wait()
+		err = recorder.closeFile()
+		if recorder.err != nil {
+			err = recorder.err
+		}
+	})
+	return
+}
+
+func (recorder *Recorder) run() {
+	defer recorder.wg.Done()
+	var ticker = time.NewTicker(RecorderFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case frame, ok := <-recorder.frames:
+			if !ok {
+				return
+			}
+			if recorder.err != nil {
+				atomic.AddUint64(&recorder.dropped, 1)
+				continue
+			}
+			recorder.err = recorder.write(frame)
+		case <-ticker.C:
+			if recorder.err != nil {
+				continue
+			}
+			recorder.err = recorder.flush()
+		}
+	}
+}
+
+func (recorder *Recorder) write(frame Frame) (err error) {
+	if recorder.written.n >= recorder.maxSize || time.Since(recorder.opened) >= recorder.maxAge {
+		err = recorder.rotate()
+		if err != nil {
+			return
+		}
+	}
+	var b []byte
+	b, err = json.Marshal(&frame)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+	_, err = recorder.buf.Write(b)
+	return
+}
+
+func (recorder *Recorder) flush() (err error) {
+	err = recorder.buf.Flush()
+	if err != nil {
+		return
+	}
+	err = recorder.zip.Flush()
+	return
+}
+
+func (recorder *Recorder) rotate() (err error) {
+	err = recorder.closeFile()
+	if err != nil {
+		return
+	}
+	recorder.opened = time.Now()
+	var name = filepath.Join(recorder.dir, fmt.Sprintf("%s-%d%s", recorder.prefix, recorder.opened.UnixNano(), RecorderExt))
+	recorder.file, err = os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	recorder.written = &counter{w: recorder.file}
+	recorder.zip = gzip.NewWriter(recorder.written)
+	recorder.buf = bufio.NewWriter(recorder.zip)
+	return
+}
+
+// closeFile flushes and closes the current file, closing it even when flushing fails.
+func (recorder *Recorder) closeFile() (err error) {
+	if recorder.file == nil {
+		return
+	}
+	err = recorder.buf.Flush()
+	if e := recorder.zip.Close(); err == nil {
+		err = e
+	}
+	if e := recorder.file.Close(); err == nil {
+		err = e
+	}
+	recorder.file, recorder.zip, recorder.buf = nil, nil, nil
+	return
+}
@@ -0,0 +1,103 @@
+package kucoin
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "kucoin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+// noise returns n incompressible bytes as a json string.
+func noise(t *testing.T, n int) json.RawMessage {
+	var b = make([]byte, n/2)
+	_, err := rand.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return json.RawMessage(`"` + hex.EncodeToString(b) + `"`)
+}
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxSize int64
+		frames  int
+		size    int
+		files   int
+	}{
+		{name: "single file", maxSize: RecorderMaxSize, frames: 100, size: 64, files: 1},
+		{name: "rotated by compressed size", maxSize: 1 << 16, frames: 16, size: 1 << 15, files: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dir = tempDir(t)
+			recorder, err := NewRecorder(dir, "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorder.maxSize = tt.maxSize
+			for i := 0; i < tt.frames; i++ {
+				recorder.frames <- Frame{Type: WebsocketMessage, Topic: "/market/level3:BTC-USDT", Time: int64(i), Data: noise(t, tt.size)}
+			}
+			err = recorder.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			files, err := ReplayFiles(dir, "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) < tt.files {
+				t.Fatalf("files = %d, want at least %d", len(files), tt.files)
+			}
+			replay, err := NewReplay(ReplayFastest, files...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var n int
+			_ = replay.Subscribe("/market/level3:BTC-USDT", "", false, false, func(data []byte) (err error) {
+				n++
+				return
+			})
+			err = replay.Listen()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.frames {
+				t.Fatalf("replayed = %d, want %d", n, tt.frames)
+			}
+		})
+	}
+}
+
+func TestRecorderStickyError(t *testing.T) {
+	recorder, err := NewRecorder(tempDir(t), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = recorder.file.Close()
+	for i := 0; i < 3; i++ {
+		recorder.frames <- Frame{Type: WebsocketMessage, Topic: "topic", Data: noise(t, 1<<20)}
+	}
+	err = recorder.Close()
+	if err == nil {
+		t.Fatal("close error = nil, want the write error")
+	}
+	if recorder.Dropped() != 2 {
+		t.Fatalf("dropped = %d, want 2", recorder.Dropped())
+	}
+	if recorder.file != nil {
+		t.Fatal("file still open after close")
+	}
+}
@@ -0,0 +1,196 @@
+package kucoin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ReplayFastest  = float64(0)
+	ReplayRealtime = float64(1)
+)
+
+type (
+	Stream interface {
+		Subscribe(topic, tunnelId string, private, ack bool, event Event) (err error)
+		Unsubscribe(topic string, private, ack bool) (err error)
+		Listen() (err error)
+		Close() (err error)
+	}
+
+	// snapshot is a recorded rest response and the time it was received.
+	snapshot struct {
+		time int64
+		data json.RawMessage
+	}
+
+	// Replay plays recorded frames back at speed times the recorded pace, or as fast as possible
+	// at ReplayFastest. Its clock is the time of the last frame played.
+	Replay struct {
+		files     []string
+		speed     float64
+		clock     int64
+		events    *sync.Map
+		snapshots map[string][]snapshot
+		ctx       context.Context
+		cancel    context.CancelFunc
+	}
+)
+
+var (
+	_ Stream = (*WebsocketConn)(nil)
+	_ Stream = (*Replay)(nil)
+)
+
+func ReplayFiles(dir, prefix string) (files []string, err error) {
+	files, err = filepath.Glob(filepath.Join(dir, prefix+"-*"+RecorderExt))
+	if err != nil {
+		return
+	}
+	sort.Strings(files)
+	return
+}
+
+func NewReplay(speed float64, files ...string) (replay *Replay, err error) {
+	replay = &Replay{
+		files:     files,
+		speed:     speed,
+		events:    new(sync.Map),
+		snapshots: make(map[string][]snapshot),
+	}
+	replay.ctx, replay.cancel = context.WithCancel(context.Background())
+	err = replay.each(func(frame *Frame) (err error) {
+		if frame.Type == RecorderRest {
+			replay.snapshots[frame.Topic] = append(replay.snapshots[frame.Topic], snapshot{time: frame.Time, data: frame.Data})
+		}
+		return
+	})
+	return
+}
+
+func (replay *Replay) Subscribe(topic, tunnelId string, private, ack bool, event Event) (err error) {
+	replay.events.Store(route{tunnelId: tunnelId, topic: topic}, event)
+	return
+}
+
+// Unsubscribe drops the handlers of topic by the routes Subscribe stored them under, whichever
+// tunnel they were subscribed on.
+func (replay *Replay) Unsubscribe(topic string, private, ack bool) (err error) {
+	replay.events.Range(func(key, value interface{}) bool {
+		if key.(route).topic == topic {
+			replay.events.Delete(key)
+		}
+		return true
+	})
+	return
+}
+
+// Snapshot returns the first response to uri recorded at or after the replay clock, which is the
+// one a request made at that point of the recording got, or the last one when all are older.
+func (replay *Replay) Snapshot(uri string) (buf *bytes.Buffer, err error) {
+	var snapshots = replay.snapshots[uri]
+	if len(snapshots) == 0 {
+		err = fmt.Errorf("replay snapshot not recorded: %s", uri)
+		return
+	}
+	var clock = atomic.LoadInt64(&replay.clock)
+	var i = sort.Search(len(snapshots), func(i int) bool {
+		return snapshots[i].time >= clock
+	})
+	if i == len(snapshots) {
+		i--
+	}
+	buf = bytes.NewBuffer(snapshots[i].data)
+	return
+}
+
+func (replay *Replay) Listen() (err error) {
+	var last int64
+	err = replay.each(func(frame *Frame) (err error) {
+		if frame.Type == RecorderRest {
+			return
+		}
+		if replay.speed > 0 && last > 0 && frame.Time > last {
+			select {
+			case <-replay.ctx.Done():
+				err = replay.ctx.Err()
+				return
+			case <-time.After(time.Duration(float64(frame.Time-last) / replay.speed)):
+			}
+		}
+		last = frame.Time
+		atomic.StoreInt64(&replay.clock, frame.Time)
+		event, ok := replay.events.Load(route{tunnelId: frame.TunnelId, topic: frame.Topic})
+		if !ok {
+			return
+		}
+		err = event.(Event)(frame.Data)
+		return
+	})
+	if err == context.Canceled {
+		err = nil
+	}
+	return
+}
+
+func (replay *Replay) Close() (err error) {
+	replay.cancel()
+	return
+}
+
+func (replay *Replay) each(f func(frame *Frame) (err error)) (err error) {
+	for _, name := range replay.files {
+		err = replay.file(name, f)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (replay *Replay) file(name string, f func(frame *Frame) (err error)) (err error) {
+	var file *os.File
+	file, err = os.Open(name)
+	if err != nil {
+		return
+	}
+	defer func() { _ = file.Close() }()
+	var zip *gzip.Reader
+	zip, err = gzip.NewReader(file)
+	if err != nil {
+		return
+	}
+	defer func() { _ = zip.Close() }()
+	var decoder = json.NewDecoder(zip)
+	for {
+		select {
+		case <-replay.ctx.Done():
+			err = replay.ctx.Err()
+			return
+		default:
+		}
+		var frame Frame
+		err = decoder.Decode(&frame)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+		err = f(&frame)
+		if err != nil {
+			return
+		}
+	}
+}
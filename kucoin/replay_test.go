@@ -0,0 +1,124 @@
+package kucoin
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestReplaySnapshot(t *testing.T) {
+	const uri = "/api/v3/market/orderbook/level3?symbol=BTC-USDT"
+	var dir = tempDir(t)
+	recorder, err := NewRecorder(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, frame := range []Frame{
+		{Type: WebsocketMessage, Topic: "topic", Time: 5, Data: []byte(`5`)},
+		{Type: RecorderRest, Topic: uri, Time: 10, Data: []byte(`"a"`)},
+		{Type: WebsocketMessage, Topic: "topic", Time: 20, Data: []byte(`20`)},
+		{Type: RecorderRest, Topic: uri, Time: 30, Data: []byte(`"b"`)},
+		{Type: WebsocketMessage, Topic: "topic", Time: 30, Data: []byte(`30`)},
+		{Type: WebsocketMessage, Topic: "topic", Time: 40, Data: []byte(`40`)},
+	} {
+		recorder.frames <- frame
+	}
+	err = recorder.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := ReplayFiles(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay, err := NewReplay(ReplayFastest, files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got = make(map[int64]string)
+	err = replay.Subscribe("topic", "", false, false, func(data []byte) (err error) {
+		clock, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return
+		}
+		buf, err := replay.Snapshot(uri)
+		if err != nil {
+			return
+		}
+		got[clock] = buf.String()
+		return
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = replay.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		clock int64
+		want  string
+	}{
+		{clock: 5, want: `"a"`},
+		{clock: 20, want: `"b"`},
+		{clock: 30, want: `"b"`},
+		{clock: 40, want: `"b"`},
+	}
+	for _, tt := range tests {
+		if got[tt.clock] != tt.want {
+			t.Errorf("snapshot at %d = %s, want %s", tt.clock, got[tt.clock], tt.want)
+		}
+	}
+	_, err = replay.Snapshot("/unknown")
+	if err == nil {
+		t.Error("snapshot of an unrecorded uri: error = nil")
+	}
+}
+
+func TestReplayUnsubscribe(t *testing.T) {
+	tests := []struct {
+		name     string
+		tunnelId string
+	}{
+		{name: "topic"},
+		{name: "tunnel", tunnelId: "a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dir = tempDir(t)
+			recorder, err := NewRecorder(dir, "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 3; i++ {
+				recorder.frames <- Frame{Type: WebsocketMessage, Topic: "topic", TunnelId: tt.tunnelId, Time: int64(i), Data: []byte(strconv.Itoa(i))}
+			}
+			err = recorder.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			files, err := ReplayFiles(dir, "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			replay, err := NewReplay(ReplayFastest, files...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var n int
+			err = replay.Subscribe("topic", tt.tunnelId, false, false, func(data []byte) (err error) {
+				n++
+				return replay.Unsubscribe("topic", false, false)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = replay.Listen()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != 1 {
+				t.Fatalf("events = %d, want 1 before unsubscribing", n)
+			}
+		})
+	}
+}
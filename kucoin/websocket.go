@@ -22,7 +22,8 @@ var (
 	WebsocketReadSize  = 1 << 8
 	WebsocketWriteSize = 1 << 8
 
-	WebsocketAckTimeout = time.Second * time.Duration(5)
+	WebsocketAckTimeout   = time.Second * time.Duration(5)
+	WebsocketCloseTimeout = time.Second * time.Duration(1)
 
 	WebsocketDialer = &websocket.Dialer{
 		Proxy:            http.ProxyFromEnvironment,
@@ -91,11 +92,11 @@ func (c *Client) PrivateToken() (token Token, err error) {
 	return
 }
 
-func (token Token) ConnectToInstance() (conn *WebsocketConn, err error) {
+func (token Token) ConnectToInstance(options ...WebsocketOption) (conn *WebsocketConn, err error) {
 	var instance = token.InstanceServers[rand.Intn(len(token.InstanceServers))]
 	switch instance.Protocol {
 	case "websocket":
-		conn, err = NewConnect(instance, token.Token)
+		conn, err = NewConnect(instance, token.Token, options...)
 	default:
 		err = fmt.Errorf("protocol not support")
 	}
@@ -105,23 +106,54 @@ func (token Token) ConnectToInstance() (conn *WebsocketConn, err error) {
 type (
 	Event func(data []byte) (err error)
 
+	WebsocketOption func(conn *WebsocketConn) (err error)
+
+	WebsocketHooks struct {
+		OnConnect    func(conn *WebsocketConn)
+		OnDisconnect func(conn *WebsocketConn, err error)
+		OnReconnect  func(conn *WebsocketConn)
+		OnError      func(conn *WebsocketConn, err error)
+	}
+
 	message struct {
-		topic string
-		data  []byte
+		topic    string
+		tunnelId string
+		data     []byte
+	}
+
+	route struct {
+		tunnelId string
+		topic    string
 	}
 
+	// handler is the event of a route and whether its topic was subscribed privately, which Close
+	// repeats when unsubscribing.
+	handler struct {
+		event   Event
+		private bool
+	}
+
+	flush chan struct{}
+
 	WebsocketConn struct {
-		srv    InstanceServer
-		conn   *websocket.Conn
-		ctx    context.Context
-		cancel context.CancelFunc
-		pp     *sync.Map
-		ack    *sync.Map
-		err    chan error
-		r      chan message
-		w      chan interface{}
-		close  int32
-		events *sync.Map
+		srv     InstanceServer
+		conn    *websocket.Conn
+		ctx     context.Context
+		cancel  context.CancelFunc
+		wg      sync.WaitGroup
+		once    sync.Once
+		cause   error
+		done    chan struct{}
+		hooks   WebsocketHooks
+		tap     Tap
+		pp      *sync.Map
+		ack     *sync.Map
+		err     chan error
+		r       chan message
+		w       chan interface{}
+		close   int32
+		events  *sync.Map
+		tunnels *sync.Map
 	}
 
 	websocketResponse struct {
@@ -146,7 +178,38 @@ type (
 	}
 )
 
-func NewConnect(server InstanceServer, token string) (conn *WebsocketConn, err error) {
+func WithWebsocketHooks(hooks WebsocketHooks) WebsocketOption {
+	return func(conn *WebsocketConn) (err error) {
+		conn.hooks = hooks
+		return
+	}
+}
+
+func (hooks WebsocketHooks) connect(conn *WebsocketConn) {
+	if hooks.OnConnect != nil {
+		hooks.OnConnect(conn)
+	}
+}
+
+func (hooks WebsocketHooks) disconnect(conn *WebsocketConn, err error) {
+	if hooks.OnDisconnect != nil {
+		hooks.OnDisconnect(conn, err)
+	}
+}
+
+func (hooks WebsocketHooks) reconnect(conn *WebsocketConn) {
+	if hooks.OnReconnect != nil {
+		hooks.OnReconnect(conn)
+	}
+}
+
+func (hooks WebsocketHooks) error(conn *WebsocketConn, err error) {
+	if hooks.OnError != nil {
+		hooks.OnError(conn, err)
+	}
+}
+
+func NewConnect(server InstanceServer, token string, options ...WebsocketOption) (conn *WebsocketConn, err error) {
 	var uri *url.URL
 	uri, err = url.Parse(server.Endpoint)
 	if err != nil {
@@ -157,63 +220,118 @@ func NewConnect(server InstanceServer, token string) (conn *WebsocketConn, err e
 	query.Set("token", token)
 	uri.RawQuery = query.Encode()
 	conn = &WebsocketConn{
-		srv:    server,
-		pp:     new(sync.Map),
-		ack:    new(sync.Map),
-		err:    make(chan error, WebsocketErrorSize),
-		r:      make(chan message, WebsocketReadSize),
-		w:      make(chan interface{}, WebsocketWriteSize),
-		events: new(sync.Map),
+		srv:     server,
+		done:    make(chan struct{}),
+		pp:      new(sync.Map),
+		ack:     new(sync.Map),
+		err:     make(chan error, WebsocketErrorSize),
+		r:       make(chan message, WebsocketReadSize),
+		w:       make(chan interface{}, WebsocketWriteSize),
+		events:  new(sync.Map),
+		tunnels: new(sync.Map),
+	}
+	for _, option := range options {
+		err = option(conn)
+		if err != nil {
+			return
+		}
 	}
 	conn.conn, _, err = WebsocketDialer.Dial(uri.String(), nil)
 	if err != nil {
+		conn.hooks.error(conn, err)
 		return
 	}
 	var welcome websocketResponse
 	err = conn.conn.ReadJSON(&welcome)
-	if err != nil {
-		return
+	if err == nil {
+		switch welcome.Type {
+		case WebsocketError:
+			err = fmt.Errorf(string(welcome.Data))
+		case WebsocketWelcome:
+		default:
+			err = fmt.Errorf("websocket not receive welcome message")
+		}
 	}
-	switch welcome.Type {
-	case WebsocketError:
-		err = fmt.Errorf(string(welcome.Data))
-		return
-	case WebsocketWelcome:
-	default:
-		err = fmt.Errorf("websocket not receive welcome message")
+	if err != nil {
+		conn.hooks.error(conn, err)
+		_ = conn.conn.Close()
 		return
 	}
 	conn.ctx, conn.cancel = context.WithCancel(context.Background())
+	conn.run(conn.heartbeat)
+	conn.run(conn.write)
+	conn.run(conn.read)
+	conn.hooks.connect(conn)
+	return
+}
+
+func (conn *WebsocketConn) run(f func() (err error)) {
+	conn.wg.Add(1)
 	go func() {
-		err = conn.heartbeat()
-		if err != nil {
-			conn.err <- err
-		}
-	}()
-	go func() {
-		err = conn.write()
-		if err != nil {
-			conn.err <- err
-		}
-	}()
-	go func() {
-		err = conn.read()
+		defer conn.wg.Done()
+		err := f()
 		if err != nil {
-			conn.err <- err
+			conn.fail(err)
 		}
 	}()
-	return
 }
 
+func (conn *WebsocketConn) fail(err error) {
+	if conn.Closed() {
+		return
+	}
+	conn.once.Do(func() { conn.cause = err })
+	conn.hooks.error(conn, err)
+	select {
+	case conn.err <- err:
+	default:
+	}
+	conn.cancel()
+}
+
+// Close unsubscribes every topic and closes every open tunnel, waits for the requests to be written
+// and closes the connection.
 func (conn *WebsocketConn) Close() (err error) {
-	if !conn.Closed() {
-		atomic.SwapInt32(&conn.close, 1)
-		conn.cancel()
-		close(conn.err)
-		close(conn.r)
-		close(conn.w)
-		err = conn.conn.Close()
+	if !atomic.CompareAndSwapInt32(&conn.close, 0, 1) {
+		return
 	}
+	if conn.ctx.Err() == nil {
+		conn.events.Range(func(key, value interface{}) bool {
+			var r = key.(route)
+			_ = conn.push(&websocketRequest{
+				Id:             strconv.FormatInt(time.Now().UnixNano(), 10),
+				Type:           WebsocketMessageUnsubscribe,
+				Topic:          r.topic,
+				TunnelId:       r.tunnelId,
+				PrivateChannel: value.(handler).private,
+			})
+			return true
+		})
+		conn.tunnels.Range(func(key, value interface{}) bool {
+			_ = conn.push(&websocketRequest{
+				Id:          strconv.FormatInt(time.Now().UnixNano(), 10),
+				Type:        WebsocketMessageCloseTunnel,
+				CloseTunnel: key.(string),
+			})
+			return true
+		})
+		var f = make(flush)
+		if conn.push(f) == nil {
+			select {
+			case <-f:
+			case <-conn.ctx.Done():
+			case <-time.After(WebsocketCloseTimeout):
+			}
+		}
+	}
+	conn.cancel()
+	var deadline = time.Now().Add(WebsocketCloseTimeout)
+	_ = conn.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), deadline)
+	_ = conn.conn.SetReadDeadline(deadline)
+	conn.wg.Wait()
+	err = conn.conn.Close()
+	conn.hooks.disconnect(conn, conn.cause)
+	close(conn.done)
 	return
 }
 
@@ -221,16 +339,71 @@ func (conn *WebsocketConn) Closed() (c bool) {
 	return atomic.LoadInt32(&conn.close) != 0
 }
 
-func (conn *WebsocketConn) wait(pool *sync.Map, id string, t time.Duration) (err error) {
-	var wait = make(chan struct{})
-	defer close(wait)
+func (conn *WebsocketConn) alive() bool {
+	return !conn.Closed() && conn.ctx.Err() == nil
+}
+
+func (conn *WebsocketConn) Done() <-chan struct{} {
+	return conn.done
+}
+
+func (conn *WebsocketConn) Err() error {
+	select {
+	case <-conn.done:
+		return conn.cause
+	default:
+		return nil
+	}
+}
+
+func (conn *WebsocketConn) push(req interface{}) (err error) {
+	select {
+	case conn.w <- req:
+	case <-conn.ctx.Done():
+		err = fmt.Errorf("websocket closed")
+	}
+	return
+}
+
+func (conn *WebsocketConn) send(req *websocketRequest) (err error) {
+	if conn.Closed() {
+		err = fmt.Errorf("websocket closed")
+		return
+	}
+	err = conn.push(req)
+	return
+}
+
+func (conn *WebsocketConn) expect(pool *sync.Map, id string) (wait chan struct{}) {
+	wait = make(chan struct{}, 1)
 	pool.Store(id, wait)
-	defer pool.Delete(id)
+	return
+}
+
+// wait blocks until the kind of response, ack or pong, expected for id arrives.
+func (conn *WebsocketConn) wait(wait chan struct{}, kind, id string, t time.Duration) (err error) {
 	select {
 	case <-wait:
+	case <-conn.ctx.Done():
+		err = fmt.Errorf("websocket closed while waiting %s: %s", kind, id)
 	case <-time.After(t):
-		err = fmt.Errorf("websocket waited ack: %s, timeout: %s", id, t)
+		err = fmt.Errorf("websocket waited %s: %s, timeout: %s", kind, id, t)
+	}
+	return
+}
+
+func (conn *WebsocketConn) request(req *websocketRequest) (err error) {
+	if !req.Response {
+		err = conn.send(req)
+		return
 	}
+	var wait = conn.expect(conn.ack, req.Id)
+	defer conn.ack.Delete(req.Id)
+	err = conn.send(req)
+	if err != nil {
+		return
+	}
+	err = conn.wait(wait, WebsocketAck, req.Id, WebsocketAckTimeout)
 	return
 }
 
@@ -239,12 +412,15 @@ func (conn *WebsocketConn) cancelWait(pool *sync.Map, id string) {
 	if !ok {
 		return
 	}
-	wait.(chan struct{}) <- struct{}{}
+	select {
+	case wait.(chan struct{}) <- struct{}{}:
+	default:
+	}
 	return
 }
 
 func (conn *WebsocketConn) Subscribe(topic, tunnelId string, private, ack bool, event Event) (err error) {
-	conn.events.Store(topic, event)
+	conn.events.Store(route{tunnelId: tunnelId, topic: topic}, handler{event: event, private: private})
 	var subscribe = &websocketRequest{
 		Id:             strconv.FormatInt(time.Now().UnixNano(), 10),
 		Type:           WebsocketMessageSubscribe,
@@ -253,26 +429,29 @@ func (conn *WebsocketConn) Subscribe(topic, tunnelId string, private, ack bool,
 		PrivateChannel: private,
 		Response:       ack,
 	}
-	conn.w <- subscribe
-	if ack {
-		err = conn.wait(conn.ack, subscribe.Id, WebsocketAckTimeout)
+	err = conn.request(subscribe)
+	if err != nil {
+		conn.events.Delete(route{tunnelId: tunnelId, topic: topic})
 	}
 	return
 }
 
 func (conn *WebsocketConn) Unsubscribe(topic string, private, ack bool) (err error) {
+	err = conn.unsubscribe(topic, "", private, ack)
+	return
+}
+
+func (conn *WebsocketConn) unsubscribe(topic, tunnelId string, private, ack bool) (err error) {
 	var unsubscribe = &websocketRequest{
 		Id:             strconv.FormatInt(time.Now().UnixNano(), 10),
 		Type:           WebsocketMessageUnsubscribe,
 		Topic:          topic,
+		TunnelId:       tunnelId,
 		PrivateChannel: private,
 		Response:       ack,
 	}
-	conn.w <- unsubscribe
-	if ack {
-		err = conn.wait(conn.ack, unsubscribe.Id, WebsocketAckTimeout)
-	}
-	conn.events.Delete(topic)
+	err = conn.request(unsubscribe)
+	conn.events.Delete(route{tunnelId: tunnelId, topic: topic})
 	return
 }
 
@@ -283,9 +462,9 @@ func (conn *WebsocketConn) OpenTunnel(tunnelId string, ack bool) (err error) {
 		NewTunnelId: tunnelId,
 		Response:    ack,
 	}
-	conn.w <- openTunnel
-	if ack {
-		err = conn.wait(conn.ack, openTunnel.Id, WebsocketAckTimeout)
+	err = conn.request(openTunnel)
+	if err == nil {
+		conn.tunnels.Store(tunnelId, struct{}{})
 	}
 	return
 }
@@ -297,10 +476,8 @@ func (conn *WebsocketConn) CloseTunnel(tunnelId string, ack bool) (err error) {
 		CloseTunnel: tunnelId,
 		Response:    ack,
 	}
-	conn.w <- closeTunnel
-	if ack {
-		err = conn.wait(conn.ack, closeTunnel.Id, WebsocketAckTimeout)
-	}
+	conn.tunnels.Delete(tunnelId)
+	err = conn.request(closeTunnel)
 	return
 }
 
@@ -311,12 +488,19 @@ func (conn *WebsocketConn) heartbeat() (err error) {
 		Id:   strconv.FormatInt(time.Now().UnixNano(), 10),
 		Type: WebsocketPing,
 	}
-	for !conn.Closed() {
+	for {
 		select {
 		case <-pt.C:
 			ping.Id = strconv.FormatInt(time.Now().UnixNano(), 10)
-			conn.w <- ping
-			err = conn.wait(conn.pp, ping.Id, time.Duration(conn.srv.PingTimeout)*time.Millisecond)
+			var wait = conn.expect(conn.pp, ping.Id)
+			if conn.push(ping) != nil {
+				return
+			}
+			err = conn.wait(wait, WebsocketPong, ping.Id, time.Duration(conn.srv.PingTimeout)*time.Millisecond)
+			conn.pp.Delete(ping.Id)
+			if conn.ctx.Err() != nil {
+				err = nil
+			}
 			if err != nil {
 				return
 			}
@@ -324,49 +508,60 @@ func (conn *WebsocketConn) heartbeat() (err error) {
 			return
 		}
 	}
-	return
 }
 
 func (conn *WebsocketConn) read() (err error) {
-	for !conn.Closed() {
-		select {
-		case <-conn.ctx.Done():
+	for {
+		var resp websocketResponse
+		err = conn.conn.ReadJSON(&resp)
+		if err != nil {
+			if conn.Closed() || conn.ctx.Err() != nil {
+				err = nil
+			}
 			return
-		default:
-			var resp websocketResponse
-			err = conn.conn.ReadJSON(&resp)
-			if err != nil {
-				return
+		}
+		switch resp.Type {
+		case WebsocketWelcome:
+			continue
+		case WebsocketError:
+			err = fmt.Errorf(string(resp.Data))
+			return
+		case WebsocketPong:
+			conn.cancelWait(conn.pp, resp.Id)
+		case WebsocketAck:
+			conn.cancelWait(conn.ack, resp.Id)
+		case WebsocketMessage, WebsocketCommand, WebsocketNotice:
+			if conn.tap != nil {
+				conn.tap(Frame{
+					Type:     resp.Type,
+					Topic:    resp.Topic,
+					Subject:  resp.Subject,
+					TunnelId: resp.TunnelId,
+					Time:     time.Now().UnixNano(),
+					Data:     resp.Data,
+				})
 			}
-			switch resp.Type {
-			case WebsocketWelcome:
-				continue
-			case WebsocketError:
-				err = fmt.Errorf(string(resp.Data))
-				return
-			case WebsocketPong:
-				go conn.cancelWait(conn.pp, resp.Id)
-			case WebsocketAck:
-				go conn.cancelWait(conn.ack, resp.Id)
-			case WebsocketMessage, WebsocketCommand, WebsocketNotice:
-				conn.r <- message{topic: resp.Topic, data: resp.Data}
-			default:
-				err = fmt.Errorf("websocket received invalid message")
+			select {
+			case conn.r <- message{topic: resp.Topic, tunnelId: resp.TunnelId, data: resp.Data}:
+			case <-conn.ctx.Done():
 				return
 			}
+		default:
+			err = fmt.Errorf("websocket received invalid message")
+			return
 		}
 	}
-	return
 }
 
 func (conn *WebsocketConn) write() (err error) {
-	for !conn.Closed() {
+	for {
 		select {
 		case <-conn.ctx.Done():
 			return
-		case req, ok := <-conn.w:
-			if !ok {
-				return
+		case req := <-conn.w:
+			if f, ok := req.(flush); ok {
+				close(f)
+				continue
 			}
 			var v []byte
 			v, err = json.Marshal(&req)
@@ -379,29 +574,30 @@ func (conn *WebsocketConn) write() (err error) {
 			}
 		}
 	}
-	return
 }
 
 func (conn *WebsocketConn) Listen() (err error) {
 	defer func() { _ = conn.Close() }()
-	for !conn.Closed() {
+	for {
 		select {
-		case <-conn.ctx.Done():
-			err = conn.ctx.Err()
 		case err = <-conn.err:
-		case msg, ok := <-conn.r:
-			if !ok {
-				return
+			return
+		case <-conn.ctx.Done():
+			select {
+			case err = <-conn.err:
+			default:
 			}
-			event, ok := conn.events.Load(msg.topic)
+			return
+		case msg := <-conn.r:
+			event, ok := conn.events.Load(route{tunnelId: msg.tunnelId, topic: msg.topic})
 			if !ok {
+				conn.hooks.error(conn, fmt.Errorf("websocket received message without handler: %s", msg.topic))
+				continue
+			}
+			err = event.(handler).event(msg.data)
+			if err != nil {
 				return
 			}
-			err = event.(Event)(msg.data)
-		}
-		if err != nil {
-			return
 		}
 	}
-	return
 }
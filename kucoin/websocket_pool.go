@@ -0,0 +1,364 @@
+package kucoin
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	WebsocketPoolTopicSize     = 300
+	WebsocketPoolRetryInterval = time.Second
+)
+
+type (
+	Dial func(options ...WebsocketOption) (conn *WebsocketConn, err error)
+
+	subscription struct {
+		topic   string
+		private bool
+		ack     bool
+		event   Event
+		placing bool
+	}
+
+	poolConn struct {
+		conn   *WebsocketConn
+		topics map[string]*subscription
+	}
+
+	// WebsocketPool spreads topics over connections of at most size topics each. Topics are
+	// pending while they are placed and when the connection they were on ends, until they are
+	// placed on another one; Unsubscribe drops pending topics too. Connections are dialed and
+	// subscribed without holding the pool lock.
+	WebsocketPool struct {
+		m       sync.Mutex
+		dial    Dial
+		size    int
+		hooks   WebsocketHooks
+		conns   []*poolConn
+		topics  map[string]*poolConn
+		pending map[string]*subscription
+		err     chan error
+		ctx     context.Context
+		cancel  context.CancelFunc
+	}
+)
+
+func (c *Client) dial(token func() (Token, error)) Dial {
+	return func(options ...WebsocketOption) (conn *WebsocketConn, err error) {
+		var t Token
+		t, err = token()
+		if err != nil {
+			return
+		}
+		conn, err = t.ConnectToInstance(options...)
+		return
+	}
+}
+
+func (c *Client) PublicDial() Dial {
+	return c.dial(c.PublicToken)
+}
+
+func (c *Client) PrivateDial() Dial {
+	return c.dial(c.PrivateToken)
+}
+
+func NewWebsocketPool(dial Dial, size int) (pool *WebsocketPool) {
+	if size <= 0 {
+		size = WebsocketPoolTopicSize
+	}
+	pool = &WebsocketPool{
+		dial:    dial,
+		size:    size,
+		topics:  make(map[string]*poolConn),
+		pending: make(map[string]*subscription),
+		err:     make(chan error, WebsocketErrorSize),
+	}
+	pool.ctx, pool.cancel = context.WithCancel(context.Background())
+	return
+}
+
+func (pool *WebsocketPool) SetHooks(hooks WebsocketHooks) {
+	pool.m.Lock()
+	defer pool.m.Unlock()
+	pool.hooks = hooks
+}
+
+func (pool *WebsocketPool) Subscribe(topic string, private, ack bool, event Event) (err error) {
+	pool.m.Lock()
+	if _, ok := pool.topics[topic]; ok {
+		pool.m.Unlock()
+		err = fmt.Errorf("websocket pool topic already subscribed: %s", topic)
+		return
+	}
+	if _, ok := pool.pending[topic]; ok {
+		pool.m.Unlock()
+		err = fmt.Errorf("websocket pool topic already subscribed: %s", topic)
+		return
+	}
+	var sub = &subscription{topic: topic, private: private, ack: ack, event: event, placing: true}
+	pool.pending[topic] = sub
+	pool.m.Unlock()
+	_, err = pool.place(sub)
+	if err != nil {
+		pool.m.Lock()
+		if pool.pending[topic] == sub {
+			delete(pool.pending, topic)
+		}
+		pool.m.Unlock()
+	}
+	return
+}
+
+func (pool *WebsocketPool) Unsubscribe(topic string, private, ack bool) (err error) {
+	pool.m.Lock()
+	defer pool.m.Unlock()
+	delete(pool.pending, topic)
+	pc, ok := pool.topics[topic]
+	if !ok {
+		return
+	}
+	delete(pool.topics, topic)
+	delete(pc.topics, topic)
+	if len(pc.topics) == 0 {
+		pool.remove(pc)
+		err = pc.conn.Close()
+		return
+	}
+	err = pc.conn.Unsubscribe(topic, private, ack)
+	return
+}
+
+func (pool *WebsocketPool) Topics() (topics []string) {
+	pool.m.Lock()
+	defer pool.m.Unlock()
+	topics = make([]string, 0, len(pool.topics)+len(pool.pending))
+	for topic := range pool.topics {
+		topics = append(topics, topic)
+	}
+	for topic := range pool.pending {
+		topics = append(topics, topic)
+	}
+	return
+}
+
+func (pool *WebsocketPool) Conns() int {
+	pool.m.Lock()
+	defer pool.m.Unlock()
+	return len(pool.conns)
+}
+
+func (pool *WebsocketPool) Listen() (err error) {
+	select {
+	case <-pool.ctx.Done():
+	case err = <-pool.err:
+		_ = pool.Close()
+	}
+	return
+}
+
+func (pool *WebsocketPool) Close() (err error) {
+	pool.cancel()
+	pool.m.Lock()
+	defer pool.m.Unlock()
+	for _, pc := range pool.conns {
+		if e := pc.conn.Close(); e != nil {
+			err = e
+		}
+	}
+	pool.conns = nil
+	return
+}
+
+// place subscribes sub, reserved in pending and marked placing, on the least loaded live
+// connection, opening one when all are full. The slot is held by sub in the connection topics while
+// the connection is dialed and subscribed without the pool lock. Once placed sub leaves pending; on
+// failure it stays there, no longer placing, and a connection opened for it alone is closed again.
+func (pool *WebsocketPool) place(sub *subscription) (opened *poolConn, err error) {
+	pool.m.Lock()
+	var pc *poolConn
+	for _, v := range pool.conns {
+		if v.conn.alive() && len(v.topics) < pool.size && (pc == nil || len(v.topics) < len(pc.topics)) {
+			pc = v
+		}
+	}
+	if pc != nil {
+		pc.topics[sub.topic] = sub
+	}
+	pool.m.Unlock()
+	if pc == nil {
+		pc, err = pool.open(sub)
+		if err != nil {
+			pool.m.Lock()
+			sub.placing = false
+			pool.m.Unlock()
+			return
+		}
+		opened = pc
+	}
+	err = pc.conn.Subscribe(sub.topic, "", sub.private, sub.ack, sub.event)
+	pool.m.Lock()
+	var reserved = pc.topics[sub.topic] == sub && pool.has(pc)
+	if reserved && (err != nil || pool.pending[sub.topic] != sub) {
+		delete(pc.topics, sub.topic)
+	}
+	var empty = len(pc.topics) == 0
+	switch {
+	case pool.pending[sub.topic] != sub:
+		// unsubscribed while it was placed.
+		pool.m.Unlock()
+		if opened != nil && empty {
+			pool.close(opened)
+		} else if err == nil && reserved {
+			_ = pc.conn.Unsubscribe(sub.topic, sub.private, false)
+		}
+		opened = nil
+		return
+	case err == nil && !reserved:
+		// the connection ended while it was subscribed; its topics are pending again.
+		err = fmt.Errorf("websocket pool connection closed: %s", sub.topic)
+	}
+	if err != nil {
+		sub.placing = false
+		pool.m.Unlock()
+		if opened != nil && empty {
+			pool.close(opened)
+		}
+		opened = nil
+		return
+	}
+	delete(pool.pending, sub.topic)
+	sub.placing = false
+	pool.topics[sub.topic] = pc
+	pool.m.Unlock()
+	return
+}
+
+// open dials a connection holding the slot of sub.
+func (pool *WebsocketPool) open(sub *subscription) (pc *poolConn, err error) {
+	select {
+	case <-pool.ctx.Done():
+		err = pool.ctx.Err()
+		return
+	default:
+	}
+	pool.m.Lock()
+	var hooks = pool.hooks
+	pool.m.Unlock()
+	var conn *WebsocketConn
+	conn, err = pool.dial(WithWebsocketHooks(hooks))
+	if err != nil {
+		return
+	}
+	pool.m.Lock()
+	defer pool.m.Unlock()
+	select {
+	case <-pool.ctx.Done():
+		_ = conn.Close()
+		err = pool.ctx.Err()
+		return
+	default:
+	}
+	pc = &poolConn{conn: conn, topics: map[string]*subscription{sub.topic: sub}}
+	pool.conns = append(pool.conns, pc)
+	go pool.listen(pc)
+	return
+}
+
+// close removes and closes pc.
+func (pool *WebsocketPool) close(pc *poolConn) {
+	pool.m.Lock()
+	pool.remove(pc)
+	pool.m.Unlock()
+	_ = pc.conn.Close()
+}
+
+func (pool *WebsocketPool) has(pc *poolConn) bool {
+	for _, v := range pool.conns {
+		if v == pc {
+			return true
+		}
+	}
+	return false
+}
+
+func (pool *WebsocketPool) remove(pc *poolConn) (found bool) {
+	for i, v := range pool.conns {
+		if v == pc {
+			pool.conns = append(pool.conns[:i], pool.conns[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (pool *WebsocketPool) listen(pc *poolConn) {
+	_ = pc.conn.Listen()
+	pool.m.Lock()
+	if !pool.remove(pc) {
+		pool.m.Unlock()
+		return
+	}
+	for topic, sub := range pc.topics {
+		delete(pool.topics, topic)
+		pool.pending[topic] = sub
+	}
+	pool.m.Unlock()
+	err := pool.rebalance()
+	if err != nil {
+		select {
+		case pool.err <- err:
+		default:
+		}
+	}
+}
+
+// rebalance places the pending topics that are not being placed already, waiting
+// WebsocketPoolRetryInterval after every failure, until none is left or the pool is closed.
+func (pool *WebsocketPool) rebalance() (err error) {
+	for {
+		var subs []*subscription
+		pool.m.Lock()
+		for _, sub := range pool.pending {
+			if !sub.placing {
+				sub.placing = true
+				subs = append(subs, sub)
+			}
+		}
+		var hooks = pool.hooks
+		pool.m.Unlock()
+		if len(subs) == 0 {
+			err = nil
+			return
+		}
+		var failed bool
+		for i, sub := range subs {
+			var pc *poolConn
+			pc, err = pool.place(sub)
+			if err != nil {
+				failed = true
+				pool.m.Lock()
+				for _, rest := range subs[i+1:] {
+					rest.placing = false
+				}
+				pool.m.Unlock()
+				break
+			}
+			if pc != nil {
+				hooks.reconnect(pc.conn)
+			}
+		}
+		if !failed {
+			continue
+		}
+		select {
+		case <-pool.ctx.Done():
+			err = pool.ctx.Err()
+			return
+		case <-time.After(WebsocketPoolRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package kucoin_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bzeron/mk/kucoin"
+	"github.com/bzeron/mk/kucoin/kucointest"
+)
+
+const timeout = 2 * time.Second
+
+func dial(t *testing.T, server *kucointest.Server, options ...kucoin.WebsocketOption) *kucoin.WebsocketConn {
+	conn, err := server.Token().ConnectToInstance(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// eventually fails t unless cond holds within timeout.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	var deadline = time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConnect(t *testing.T) {
+	tests := []struct {
+		name    string
+		reject  bool
+		wantErr string
+	}{
+		{name: "welcome"},
+		{name: "rejected", reject: true, wantErr: "token invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var server = kucointest.NewServer()
+			defer server.Close()
+			if tt.reject {
+				server.RejectWelcome(401, "token invalid")
+			}
+			var connected int
+			conn, err := server.Token().ConnectToInstance(kucoin.WithWebsocketHooks(kucoin.WebsocketHooks{
+				OnConnect: func(conn *kucoin.WebsocketConn) { connected++ },
+			}))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = conn.Close() }()
+			if connected != 1 {
+				t.Fatalf("connected = %d, want 1", connected)
+			}
+		})
+	}
+}
+
+func TestSubscribeAck(t *testing.T) {
+	defer func(timeout time.Duration) { kucoin.WebsocketAckTimeout = timeout }(kucoin.WebsocketAckTimeout)
+	kucoin.WebsocketAckTimeout = 200 * time.Millisecond
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		wantErr string
+	}{
+		{name: "acked"},
+		{name: "ack timeout", delay: 500 * time.Millisecond, wantErr: "waited ack"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var server = kucointest.NewServer()
+			defer server.Close()
+			server.DelayAcks(tt.delay)
+			var errs = make(chan error, 1)
+			var conn = dial(t, server, kucoin.WithWebsocketHooks(kucoin.WebsocketHooks{
+				OnError: func(conn *kucoin.WebsocketConn, err error) {
+					select {
+					case errs <- err:
+					default:
+					}
+				},
+			}))
+			go func() { _ = conn.Listen() }()
+			var events = make(chan string, 1)
+			err := conn.Subscribe("/market/ticker:BTC-USDT", "", false, true, func(data []byte) (err error) {
+				events <- string(data)
+				return
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				// the server routed the topic anyway; the handler must be gone.
+				server.Push("/market/ticker:BTC-USDT", "trade.ticker", "late")
+				select {
+				case err = <-errs:
+					if !strings.Contains(err.Error(), "without handler") {
+						t.Fatalf("hook err = %v, want a message without handler", err)
+					}
+				case data := <-events:
+					t.Fatalf("stale handler received %s", data)
+				case <-time.After(timeout):
+					t.Fatal("timeout waiting the unhandled message")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			server.Push("/market/ticker:BTC-USDT", "trade.ticker", "price")
+			select {
+			case data := <-events:
+				if data != `"price"` {
+					t.Fatalf("data = %s, want \"price\"", data)
+				}
+			case <-time.After(timeout):
+				t.Fatal("timeout waiting the message")
+			}
+		})
+	}
+}
+
+func TestHeartbeatTimeout(t *testing.T) {
+	var server = kucointest.NewServer()
+	defer server.Close()
+	server.SetPing(1100*time.Millisecond, 200*time.Millisecond)
+	server.DropPongs(true)
+	var conn = dial(t, server)
+	var done = make(chan error, 1)
+	go func() { done <- conn.Listen() }()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "waited pong") {
+			t.Fatalf("err = %v, want a pong timeout", err)
+		}
+	case <-time.After(timeout):
+		t.Fatal("timeout waiting the heartbeat to fail")
+	}
+	if conn.Err() == nil {
+		t.Fatal("conn err = nil after heartbeat timeout")
+	}
+}
+
+func TestPoolRebalance(t *testing.T) {
+	defer func(interval time.Duration) { kucoin.WebsocketPoolRetryInterval = interval }(kucoin.WebsocketPoolRetryInterval)
+	kucoin.WebsocketPoolRetryInterval = 50 * time.Millisecond
+	var server = kucointest.NewServer()
+	defer server.Close()
+	client, err := server.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pool = kucoin.NewWebsocketPool(client.PublicDial(), 1)
+	defer func() { _ = pool.Close() }()
+	var reconnected = make(chan struct{}, 4)
+	pool.SetHooks(kucoin.WebsocketHooks{
+		OnReconnect: func(conn *kucoin.WebsocketConn) { reconnected <- struct{}{} },
+	})
+	var topics = []string{"/market/level2:BTC-USDT", "/market/level2:ETH-USDT"}
+	var events = make(chan string, len(topics))
+	for _, topic := range topics {
+		var topic = topic
+		err = pool.Subscribe(topic, false, true, func(data []byte) (err error) {
+			events <- topic
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if pool.Conns() != len(topics) {
+		t.Fatalf("conns = %d, want %d", pool.Conns(), len(topics))
+	}
+	server.Disconnect()
+	for range topics {
+		select {
+		case <-reconnected:
+		case <-time.After(timeout):
+			t.Fatal("timeout waiting reconnect")
+		}
+	}
+	if pool.Conns() != len(topics) {
+		t.Fatalf("conns after rebalance = %d, want %d", pool.Conns(), len(topics))
+	}
+	if got := len(pool.Topics()); got != len(topics) {
+		t.Fatalf("topics = %d, want %d", got, len(topics))
+	}
+	for _, topic := range topics {
+		err = server.WaitSubscribed(topic, 1, timeout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.Push(topic, "trade.l2update", "update")
+		select {
+		case got := <-events:
+			if got != topic {
+				t.Fatalf("event of %s, want %s", got, topic)
+			}
+		case <-time.After(timeout):
+			t.Fatalf("timeout waiting %s", topic)
+		}
+	}
+}
+
+func TestGracefulClose(t *testing.T) {
+	var server = kucointest.NewServer()
+	defer server.Close()
+	var conn = dial(t, server)
+	var done = make(chan error, 1)
+	go func() { done <- conn.Listen() }()
+	for _, topic := range []string{"/market/level3:BTC-USDT", "/spotMarket/tradeOrders"} {
+		err := conn.Subscribe(topic, "", strings.HasPrefix(topic, "/spot"), true, func(data []byte) (err error) { return })
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var sc = server.Conns()[0]
+	err := conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatalf("listen err = %v, want nil", err)
+		}
+	case <-time.After(timeout):
+		t.Fatal("timeout waiting listen to return")
+	}
+	eventually(t, "server side close", func() bool { return len(server.Conns()) == 0 })
+	var unsubscribes int
+	for _, req := range server.Requests() {
+		if req == kucoin.WebsocketMessageUnsubscribe {
+			unsubscribes++
+		}
+	}
+	if unsubscribes != 2 {
+		t.Fatalf("unsubscribes = %d, want 2", unsubscribes)
+	}
+	if sc.Subscribed("", "/market/level3:BTC-USDT") || sc.Subscribed("", "/spotMarket/tradeOrders") {
+		t.Fatal("topics still routed after close")
+	}
+	if !conn.Closed() || conn.Err() != nil {
+		t.Fatalf("closed = %v, err = %v, want closed without error", conn.Closed(), conn.Err())
+	}
+}
+
+func TestPoolDialUnlocked(t *testing.T) {
+	var server = kucointest.NewServer()
+	defer server.Close()
+	client, err := server.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dial = client.PublicDial()
+	var gate = make(chan struct{})
+	var dials int
+	var pool = kucoin.NewWebsocketPool(func(options ...kucoin.WebsocketOption) (*kucoin.WebsocketConn, error) {
+		if dials++; dials > 1 {
+			<-gate
+		}
+		return dial(options...)
+	}, 1)
+	defer func() { _ = pool.Close() }()
+	var event = func(data []byte) (err error) { return }
+	err = pool.Subscribe("/market/level2:BTC-USDT", false, true, event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subscribed = make(chan error, 1)
+	go func() {
+		subscribed <- pool.Subscribe("/market/level2:ETH-USDT", false, true, event)
+	}()
+	eventually(t, "the slow topic to be reserved", func() bool { return len(pool.Topics()) == 2 })
+	var done = make(chan error, 1)
+	go func() {
+		done <- pool.Unsubscribe("/market/level2:BTC-USDT", false, true)
+	}()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(timeout):
+		t.Fatal("unsubscribe blocked by a dial")
+	}
+	close(gate)
+	select {
+	case err = <-subscribed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(timeout):
+		t.Fatal("timeout waiting the slow subscribe")
+	}
+	if got := pool.Topics(); len(got) != 1 || got[0] != "/market/level2:ETH-USDT" {
+		t.Fatalf("topics = %v, want the slow topic", got)
+	}
+	if pool.Conns() != 1 {
+		t.Fatalf("conns = %d, want 1", pool.Conns())
+	}
+}
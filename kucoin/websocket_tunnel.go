@@ -0,0 +1,82 @@
+package kucoin
+
+import (
+	"fmt"
+	"sync"
+)
+
+type Tunnel struct {
+	m      sync.Mutex
+	id     string
+	conn   *WebsocketConn
+	topics map[string]*subscription
+	closed bool
+}
+
+func (conn *WebsocketConn) NewTunnel(tunnelId string, ack bool) (tunnel *Tunnel, err error) {
+	err = conn.OpenTunnel(tunnelId, ack)
+	if err != nil {
+		return
+	}
+	tunnel = &Tunnel{
+		id:     tunnelId,
+		conn:   conn,
+		topics: make(map[string]*subscription),
+	}
+	return
+}
+
+func (tunnel *Tunnel) Id() string {
+	return tunnel.id
+}
+
+func (tunnel *Tunnel) Topics() (topics []string) {
+	tunnel.m.Lock()
+	defer tunnel.m.Unlock()
+	topics = make([]string, 0, len(tunnel.topics))
+	for topic := range tunnel.topics {
+		topics = append(topics, topic)
+	}
+	return
+}
+
+func (tunnel *Tunnel) Subscribe(topic string, private, ack bool, event Event) (err error) {
+	tunnel.m.Lock()
+	defer tunnel.m.Unlock()
+	if tunnel.closed {
+		err = fmt.Errorf("websocket tunnel closed: %s", tunnel.id)
+		return
+	}
+	err = tunnel.conn.Subscribe(topic, tunnel.id, private, ack, event)
+	if err != nil {
+		return
+	}
+	tunnel.topics[topic] = &subscription{topic: topic, private: private, ack: ack, event: event}
+	return
+}
+
+func (tunnel *Tunnel) Unsubscribe(topic string, private, ack bool) (err error) {
+	tunnel.m.Lock()
+	defer tunnel.m.Unlock()
+	if _, ok := tunnel.topics[topic]; !ok {
+		return
+	}
+	delete(tunnel.topics, topic)
+	err = tunnel.conn.unsubscribe(topic, tunnel.id, private, ack)
+	return
+}
+
+func (tunnel *Tunnel) Close(ack bool) (err error) {
+	tunnel.m.Lock()
+	defer tunnel.m.Unlock()
+	if tunnel.closed {
+		return
+	}
+	tunnel.closed = true
+	for topic := range tunnel.topics {
+		tunnel.conn.events.Delete(route{tunnelId: tunnel.id, topic: topic})
+	}
+	tunnel.topics = make(map[string]*subscription)
+	err = tunnel.conn.CloseTunnel(tunnel.id, ack)
+	return
+}
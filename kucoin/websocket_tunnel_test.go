@@ -0,0 +1,105 @@
+package kucoin_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bzeron/mk/kucoin"
+	"github.com/bzeron/mk/kucoin/kucointest"
+)
+
+func TestTunnelRouting(t *testing.T) {
+	const topic = "/market/level3:BTC-USDT"
+	var server = kucointest.NewServer()
+	defer server.Close()
+	var conn = dial(t, server)
+	go func() { _ = conn.Listen() }()
+	var events = make(chan string, 8)
+	var tunnels = make(map[string]*kucoin.Tunnel)
+	for _, id := range []string{"a", "b"} {
+		var id = id
+		tunnel, err := conn.NewTunnel(id, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tunnel.Subscribe(topic, false, true, func(data []byte) (err error) {
+			events <- id + ":" + string(data)
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tunnels[id] = tunnel
+	}
+	tests := []struct {
+		tunnel string
+		want   string
+	}{
+		{tunnel: "a", want: `a:"1"`},
+		{tunnel: "b", want: `b:"2"`},
+		{tunnel: "a", want: `a:"3"`},
+	}
+	for i, tt := range tests {
+		server.PushTunnel(tt.tunnel, topic, "trade.l3received", strconv.Itoa(i+1))
+		select {
+		case got := <-events:
+			if got != tt.want {
+				t.Fatalf("event = %s, want %s", got, tt.want)
+			}
+		case <-time.After(timeout):
+			t.Fatalf("timeout waiting %s", tt.want)
+		}
+	}
+	err := tunnels["a"].Close(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := server.PushTunnel("a", topic, "trade.l3received", "4"); n != 0 {
+		t.Fatalf("pushed to %d conns after the tunnel closed, want 0", n)
+	}
+	server.PushTunnel("b", topic, "trade.l3received", "5")
+	select {
+	case got := <-events:
+		if got != `b:"5"` {
+			t.Fatalf("event = %s, want b:\"5\"", got)
+		}
+	case <-time.After(timeout):
+		t.Fatal("timeout waiting the open tunnel")
+	}
+}
+
+func TestCloseTunnels(t *testing.T) {
+	var server = kucointest.NewServer()
+	defer server.Close()
+	var conn = dial(t, server)
+	go func() { _ = conn.Listen() }()
+	for _, id := range []string{"a", "b"} {
+		tunnel, err := conn.NewTunnel(id, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tunnel.Subscribe("/market/level3:BTC-USDT", false, true, func(data []byte) (err error) { return })
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var sc = server.Conns()[0]
+	err := conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "server side close", func() bool { return len(server.Conns()) == 0 })
+	var closed int
+	for _, req := range server.Requests() {
+		if req == kucoin.WebsocketMessageCloseTunnel {
+			closed++
+		}
+	}
+	if closed != 2 {
+		t.Fatalf("closed tunnels = %d, want 2", closed)
+	}
+	if sc.Subscribed("a", "/market/level3:BTC-USDT") || sc.Subscribed("b", "/market/level3:BTC-USDT") {
+		t.Fatal("tunnel topics still routed after close")
+	}
+}
@@ -9,43 +9,65 @@ import (
 	"net/http/pprof"
 	"net/url"
 	"os"
-	"time"
+	"strings"
+
+	"github.com/shopspring/decimal"
 
 	"github.com/bzeron/mk/book"
+	"github.com/bzeron/mk/tui"
 
 	"github.com/bzeron/mk/kucoin"
 	_ "github.com/joho/godotenv/autoload"
 )
 
+const printLevels = 10
+
 var (
 	client *kucoin.Client
+	fetch  = send
 	symbol = "BTC-USDT"
 	clTerm = "\033[2J"
 	upTerm = "\033[%dA"
 	deTerm = "\033[K"
 )
 
-func init() {
-	var err error
-	client, err = kucoin.NewClient(
+func newClient(options ...kucoin.Option) (err error) {
+	options = append([]kucoin.Option{
 		kucoin.WithEndpoint("https://api.kucoin.com"),
 		kucoin.WithAuth(os.Getenv("KEY"), os.Getenv("SECRET"), os.Getenv("PASSPHRASE")),
-	)
+	}, options...)
+	client, err = kucoin.NewClient(options...)
+	return
+}
+
+func send(endpoint string, query url.Values) (buffer *bytes.Buffer, err error) {
+	var request *kucoin.CallRequest
+	request, err = client.NewCallRequest(http.MethodGet, endpoint, nil, query, nil)
 	if err != nil {
-		panic(err)
+		return
 	}
+	buffer, err = client.Send(request)
+	return
+}
+
+// subscriber adapts a stream to book.Subscriber, subscribing without a tunnel.
+type subscriber struct {
+	kucoin.Stream
+}
+
+func (s subscriber) Subscribe(topic string, private, ack bool, event kucoin.Event) (err error) {
+	return s.Stream.Subscribe(topic, "", private, ack, event)
 }
 
 func snapshot(l3 *book.L3) (err error) {
+	return snapshotSymbol(symbol, l3)
+}
+
+func snapshotSymbol(symbol string, l3 *book.L3) (err error) {
 	var query = url.Values{}
 	query.Set("symbol", symbol)
-	var request *kucoin.CallRequest
-	request, err = client.NewCallRequest(http.MethodGet, "/api/v1/market/orderbook/level3", nil, query, nil)
-	if err != nil {
-		return
-	}
 	var buffer *bytes.Buffer
-	buffer, err = client.Send(request)
+	buffer, err = fetch("/api/v1/market/orderbook/level3", query)
 	if err != nil {
 		return
 	}
@@ -53,31 +75,28 @@ func snapshot(l3 *book.L3) (err error) {
 	return
 }
 
-func printBookL2(l2 *book.L2) {
-	level := 10
+func printBookL2(asks, bids []book.Level) {
 	var i = 1
-	for ; i <= level*2+1; i++ {
+	for ; i <= printLevels*2+1; i++ {
 		fmt.Printf(upTerm, i)
 		fmt.Printf(deTerm)
 	}
-	asks, bids := l2.Object(level)
-	for _, v := range asks {
-		fmt.Println(v)
+	for i := len(asks) - 1; i >= 0; i-- {
+		fmt.Println(&book.OrderL2{Price: asks[i].Price, Size: asks[i].Size})
 	}
 	fmt.Println("-------------------------------")
 	for _, v := range bids {
-		fmt.Println(v)
+		fmt.Println(&book.OrderL2{Price: v.Price, Size: v.Size})
 	}
 }
 
 func printBookL3(l3 *book.L3) {
-	level := 10
 	var i = 1
-	for ; i <= level*2+1; i++ {
+	for ; i <= printLevels*2+1; i++ {
 		fmt.Printf(upTerm, i)
 		fmt.Printf(deTerm)
 	}
-	asks, bids := l3.Object(level)
+	asks, bids := l3.Object(printLevels)
 	for _, v := range asks {
 		fmt.Println(v)
 	}
@@ -88,72 +107,61 @@ func printBookL3(l3 *book.L3) {
 }
 
 func printBook(printOut string, l3 *book.L3) {
+	feed := book.NewFeed(true)
 	switch printOut {
 	case "l2":
+		l3.Observe(feed)
 		fmt.Printf(clTerm)
-		for {
-			printBookL2(l3.ToL2())
-			time.Sleep(time.Second / 100)
+		var asks, bids []book.Level
+		for range feed.C() {
+			feed.Drain()
+			asks, bids = l3.AppendLevels(asks[:0], bids[:0], printLevels)
+			printBookL2(asks, bids)
 		}
 	case "l3":
-		for {
+		l3.Observe(feed)
+		for range feed.C() {
+			feed.Drain()
 			printBookL3(l3)
-			time.Sleep(time.Second / 100)
 		}
 	default:
 	}
 }
 
-type Message struct {
-	Sequence     book.Sequence `json:"sequence"`
-	Side         string        `json:"side"`
-	Type         string        `json:"type"`
-	Size         string        `json:"size"`
-	OrderId      string        `json:"orderId"`
-	Price        string        `json:"price"`
-	Time         string        `json:"time"`
-	MakerOrderId string        `json:"makerOrderId"`
-	NewSize      string        `json:"newSize"`
+func eventWithBookL3(printOut string) kucoin.Event {
+	l3 := book.NewL3()
+	go printBook(printOut, l3)
+	return book.NewSyncL3(l3, snapshot).Event
 }
 
-func event(l3 *book.L3, msg Message) (err error) {
-	l3.SetSequence(msg.Sequence)
-	switch msg.Type {
-	case "received":
-	case "open":
-		err = l3.Add(msg.OrderId, msg.Side, msg.Price, msg.Size, msg.Time)
-	case "done":
-		l3.Del(msg.OrderId)
-	case "change":
-		err = l3.NewSize(msg.OrderId, msg.NewSize)
-	case "match":
-		err = l3.SubSize(msg.MakerOrderId, msg.Size)
+func runUI(conn kucoin.Stream, symbols []string, levels int, tick decimal.Decimal) (err error) {
+	defer func() { _ = conn.Close() }()
+	manager := book.NewManager(subscriber{conn}, snapshotSymbol)
+	listened := make(chan error, 1)
+	listen := func() {
+		go func() {
+			listened <- conn.Listen()
+		}()
 	}
-	return
-}
-
-func eventWithBookL3(printOut string) func(data []byte) (err error) {
-	l3 := book.NewL3()
-	err := snapshot(l3)
-	if err != nil {
-		panic(err)
+	// the ui subscribes with acks, which only arrive once a websocket is read, while a replay
+	// plays its frames whether anyone subscribed or not, so it is only started after the ui.
+	_, replay := conn.(*kucoin.Replay)
+	if !replay {
+		listen()
 	}
-	go printBook(printOut, l3)
-	return func(data []byte) (err error) {
-		var msg Message
-		err = json.Unmarshal(data, &msg)
-		if err != nil {
-			return
-		}
-		switch {
-		case msg.Sequence == l3.GetSequence()+1:
-			err = event(l3, msg)
-		case msg.Sequence <= l3.GetSequence():
-		case msg.Sequence > l3.GetSequence():
-			err = snapshot(l3)
-		}
+	ui, err := tui.New(manager, tui.Options{Symbols: symbols, Levels: levels, Tick: tick})
+	if err != nil {
 		return
 	}
+	defer ui.Close()
+	if replay {
+		listen()
+	}
+	go func() {
+		ui.Stop(<-listened)
+	}()
+	err = ui.Run()
+	return
 }
 
 func pprofServer(enable bool) {
@@ -172,21 +180,89 @@ func pprofServer(enable bool) {
 	}
 }
 
+func connect(record, replay string, speed float64) (stream kucoin.Stream, recorder *kucoin.Recorder, err error) {
+	if replay != "" {
+		var files []string
+		files, err = kucoin.ReplayFiles(replay, symbol)
+		if err != nil {
+			return
+		}
+		var r *kucoin.Replay
+		r, err = kucoin.NewReplay(speed, files...)
+		if err != nil {
+			return
+		}
+		fetch = func(endpoint string, query url.Values) (*bytes.Buffer, error) {
+			return r.Snapshot(endpoint + "?" + query.Encode())
+		}
+		stream = r
+		return
+	}
+	var options []kucoin.WebsocketOption
+	if record != "" {
+		recorder, err = kucoin.NewRecorder(record, symbol)
+		if err != nil {
+			return
+		}
+		err = newClient(kucoin.WithRestTap(recorder.Record))
+		if err != nil {
+			return
+		}
+		options = append(options, kucoin.WithTap(recorder.Record))
+	}
+	var token kucoin.Token
+	token, err = client.PrivateToken()
+	if err != nil {
+		return
+	}
+	stream, err = token.ConnectToInstance(options...)
+	return
+}
+
 func main() {
 	var printOut string
 	var enablePprof bool
+	var record, replay string
+	var enableUI bool
+	var symbols, tick string
+	var levels int
+	var speed float64
 	flag.StringVar(&printOut, "print", "", "l2 or l3")
+	flag.BoolVar(&enableUI, "ui", false, "interactive order book")
+	flag.StringVar(&symbols, "symbols", symbol, "comma separated symbols of the ui")
+	flag.IntVar(&levels, "levels", 50, "levels per side of the ui")
+	flag.StringVar(&tick, "tick", "0", "price aggregation tick of the ui, 0 for none")
 	flag.BoolVar(&enablePprof, "pprof", false, "pprof enable")
+	flag.StringVar(&record, "record", "", "record websocket frames and snapshots into dir")
+	flag.StringVar(&replay, "replay", "", "replay recorded frames from dir")
+	flag.Float64Var(&speed, "speed", kucoin.ReplayRealtime, "replay speed relative to the recording, 0 for as fast as possible")
 	flag.Parse()
+	var uiSymbols = strings.Split(symbols, ",")
+	symbol = uiSymbols[0]
 	go pprofServer(enablePprof)
-	token, err := client.PrivateToken()
+	err := newClient()
 	if err != nil {
 		panic(err)
 	}
-	conn, err := token.ConnectToInstance()
+	conn, recorder, err := connect(record, replay, speed)
 	if err != nil {
 		panic(err)
 	}
+	if recorder != nil {
+		defer func() { _ = recorder.Close() }()
+	}
+	if enableUI {
+		var uiTick decimal.Decimal
+		uiTick, err = decimal.NewFromString(tick)
+		if err != nil {
+			panic(err)
+		}
+		err = runUI(conn, uiSymbols, levels, uiTick)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
 	err = conn.Subscribe(fmt.Sprintf("/market/level3:%s", symbol), "", false, true, eventWithBookL3(printOut))
 	if err != nil {
 		panic(err)
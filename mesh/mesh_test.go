@@ -0,0 +1,46 @@
+package mesh
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/bzeron/mk/kucoin"
+	"github.com/bzeron/mk/kucoin/kucointest"
+)
+
+func TestOrderOperate(t *testing.T) {
+	var server = kucointest.NewServer()
+	defer server.Close()
+	server.SetCredentials("key", "secret", "passphrase")
+	client, err := server.Client(kucoin.WithAuth("key", "secret", "passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var operate = NewOrderOperate(client, "BTC-USDT")
+	orderId, err := operate.order("buy", decimal.RequireFromString("100.5"), decimal.RequireFromString("0.01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var orders = server.Orders()
+	if len(orders) != 1 || orders[0].Id != orderId || !orders[0].IsActive {
+		t.Fatalf("orders = %+v, want %s active", orders, orderId)
+	}
+	if !orders[0].Price.Equal(decimal.RequireFromString("100.5")) || orders[0].Symbol != "BTC-USDT" || orders[0].Side != "buy" {
+		t.Fatalf("order = %+v, want a buy of BTC-USDT at 100.5", orders[0])
+	}
+	cancelled, err := operate.cancel(orderId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cancelled) != 1 || cancelled[0] != orderId {
+		t.Fatalf("cancelled = %v, want %s", cancelled, orderId)
+	}
+	if orders = server.Orders(); orders[0].IsActive || !orders[0].CancelExist {
+		t.Fatalf("order = %+v, want it cancelled", orders[0])
+	}
+	_, err = operate.cancel(orderId)
+	if err == nil {
+		t.Fatal("cancel of a cancelled order: err = nil")
+	}
+}
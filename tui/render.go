@@ -0,0 +1,226 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/bzeron/mk/book"
+)
+
+const (
+	// header and footer are the lines around the ladder and trades panel.
+	header = 4
+	footer = 1
+
+	cellWidth   = 14
+	textWidth   = 3*cellWidth + 3
+	tradesWidth = 34
+	// panelCols is the terminal width from which the trades panel is shown.
+	panelCols = 90
+	separator = " │ "
+	// separatorWidth is the columns taken by separator.
+	separatorWidth = 3
+	help           = "q quit  ←/→ 1-9 symbol  ↑/↓ pgup/pgdn scroll  c center  +/- levels  [/] tick"
+)
+
+// row is a ladder level with the size resting from the best price up to and including it.
+type row struct {
+	side  string
+	level book.Level
+	total decimal.Decimal
+}
+
+// load reads up to ui.levels rows of each side, best first, aggregated at the tick.
+func (ui *UI) load(l3 *book.L3) (asks, bids []row) {
+	var a, b = l3.Levels(ui.levels, ui.tick)
+	return cumulate(book.Asks, a), cumulate(book.Bids, b)
+}
+
+// cumulate adds to levels the size resting from the best level up to each of them.
+func cumulate(side string, levels []book.Level) (rows []row) {
+	var total decimal.Decimal
+	rows = make([]row, len(levels))
+	for i, level := range levels {
+		total = total.Add(level.Size)
+		rows[i] = row{side: side, level: level, total: total}
+	}
+	return
+}
+
+func (ui *UI) draw() {
+	ui.drawn = time.Now()
+	if ui.rows <= header+footer {
+		return
+	}
+	var l3 = ui.books[ui.current]
+	var asks, bids = ui.load(l3)
+	var height = ui.rows - header - footer
+	var panel = ui.cols >= panelCols
+	var width = ui.cols
+	if panel {
+		width -= separatorWidth + tradesWidth
+	}
+	var lines = make([]string, 0, ui.rows)
+	lines = append(lines, ui.tabs(), ui.quote(l3), ui.status())
+	var titles = pad(fmt.Sprintf("%*s %*s %*s", cellWidth, "price", cellWidth, "size", cellWidth, "total"), width)
+	if panel {
+		titles += separator + fmt.Sprintf("%-12s %11s %9s", "time", "price", "size")
+	}
+	lines = append(lines, bold+titles+reset)
+	var ladder = ui.ladder(l3, asks, bids, height, width)
+	var trades []book.Trade
+	if panel {
+		trades = ui.tapes[ui.current].Last(height)
+	}
+	for i, line := range ladder {
+		if panel {
+			line += separator
+			if i < len(trades) {
+				line += trade(trades[i])
+			}
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, dim+help+reset)
+	var b bytes.Buffer
+	b.WriteString(home)
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString(clearLine)
+	}
+	b.WriteString(clearBelow)
+	_, _ = ui.term.out.Write(b.Bytes())
+}
+
+func (ui *UI) tabs() string {
+	var b strings.Builder
+	for i, symbol := range ui.symbols {
+		if i == ui.current {
+			fmt.Fprintf(&b, " %s%d %s%s", reverse, i+1, symbol, reset)
+		} else {
+			fmt.Fprintf(&b, " %d %s", i+1, symbol)
+		}
+	}
+	return b.String()
+}
+
+func (ui *UI) quote(l3 *book.L3) string {
+	bid, hasBid := l3.BestBid()
+	ask, hasAsk := l3.BestAsk()
+	if !hasBid || !hasAsk {
+		return " no quote"
+	}
+	var line = fmt.Sprintf(" bid %s%s%s x %s  ask %s%s%s x %s  spread %s", green, bid.Price, reset, bid.Size, red, ask.Price, reset, ask.Size, ask.Price.Sub(bid.Price))
+	if bps, ok := l3.SpreadBps(); ok {
+		line += fmt.Sprintf(" (%s bps)", bps.StringFixed(2))
+	}
+	if mid, ok := l3.Mid(); ok {
+		line += fmt.Sprintf("  mid %s", mid)
+	}
+	return line
+}
+
+func (ui *UI) status() string {
+	var tick = "none"
+	if ui.tick.Sign() > 0 {
+		tick = ui.tick.String()
+	}
+	var line = fmt.Sprintf(" levels %d  tick %s", ui.levels, tick)
+	if health, ok := ui.manager.Health(ui.symbols[ui.current]); ok {
+		line = fmt.Sprintf(" %s  sequence %d  lag %s ", health.State, health.Sequence, health.Lag.Round(time.Millisecond)) + line
+		if health.Err != nil {
+			line += "  " + health.Err.Error()
+		}
+	}
+	return dim + line + reset
+}
+
+// ladder lays asks above bids around a spread line, scrolled by ui.scroll from the spread.
+func (ui *UI) ladder(l3 *book.L3, asks, bids []row, height, width int) (lines []string) {
+	var rows = make([]*row, 0, len(asks)+len(bids)+1)
+	for i := len(asks) - 1; i >= 0; i-- {
+		rows = append(rows, &asks[i])
+	}
+	rows = append(rows, nil)
+	for i := range bids {
+		rows = append(rows, &bids[i])
+	}
+	var start = len(asks) + ui.scroll - height/2
+	switch {
+	case len(rows) <= height:
+		start = len(asks) - height/2
+	case start > len(rows)-height:
+		start = len(rows) - height
+	case start < 0:
+		start = 0
+	}
+	ui.scroll = start + height/2 - len(asks)
+	var max decimal.Decimal
+	if len(asks) > 0 {
+		max = asks[len(asks)-1].total
+	}
+	if len(bids) > 0 {
+		max = decimal.Max(max, bids[len(bids)-1].total)
+	}
+	var bar = width - textWidth
+	for i := start; i < start+height; i++ {
+		switch {
+		case i < 0 || i >= len(rows):
+			lines = append(lines, strings.Repeat(" ", width))
+		case rows[i] == nil:
+			lines = append(lines, ui.spread(l3, width))
+		default:
+			lines = append(lines, level(rows[i], max, bar))
+		}
+	}
+	return
+}
+
+func (ui *UI) spread(l3 *book.L3, width int) string {
+	var text string
+	if mid, ok := l3.Mid(); ok {
+		text = fmt.Sprintf("%*.*s ", cellWidth, cellWidth, mid)
+	}
+	return dim + pad(text+strings.Repeat("─", 2*cellWidth+1), width) + reset
+}
+
+// level draws a ladder row with a bar of its total size relative to max.
+func level(r *row, max decimal.Decimal, bar int) string {
+	var color = green
+	if r.side == book.Asks {
+		color = red
+	}
+	var line = fmt.Sprintf("%*.*s %*.*s %*.*s ", cellWidth, cellWidth, r.level.Price, cellWidth, cellWidth, r.level.Size, cellWidth, cellWidth, r.total)
+	if bar <= 0 {
+		return color + line + reset
+	}
+	var n int
+	if max.Sign() > 0 {
+		f, _ := r.total.Div(max).Float64()
+		n = int(f * float64(bar))
+	}
+	return color + line + strings.Repeat("█", n) + strings.Repeat(" ", bar-n) + reset
+}
+
+func trade(t book.Trade) string {
+	var color = green
+	if t.Side == book.Asks {
+		color = red
+	}
+	return color + fmt.Sprintf("%-12s %11.11s %9.9s", time.Unix(0, t.Time).Format("15:04:05.000"), t.Price, t.Size) + reset
+}
+
+// pad fills s with spaces up to width columns.
+func pad(s string, width int) string {
+	if n := width - len([]rune(s)); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
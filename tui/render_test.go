@@ -0,0 +1,70 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/bzeron/mk/book"
+)
+
+func TestCumulate(t *testing.T) {
+	var levels = []book.Level{
+		{Price: decimal.RequireFromString("10.1"), Size: decimal.RequireFromString("1")},
+		{Price: decimal.RequireFromString("10.2"), Size: decimal.RequireFromString("2.5")},
+		{Price: decimal.RequireFromString("10.3"), Size: decimal.RequireFromString("0.5")},
+	}
+	var rows = cumulate(book.Asks, levels)
+	for i, want := range []string{"1", "3.5", "4"} {
+		if rows[i].side != book.Asks || !rows[i].total.Equal(decimal.RequireFromString(want)) {
+			t.Fatalf("row %d = %+v, want a total of %s", i, rows[i], want)
+		}
+	}
+}
+
+func TestLevel(t *testing.T) {
+	tests := []struct {
+		name  string
+		total string
+		max   string
+		bar   int
+		fill  int
+	}{
+		{name: "half", total: "5", max: "10", bar: 10, fill: 5},
+		{name: "full", total: "10", max: "10", bar: 10, fill: 10},
+		{name: "empty book", total: "0", max: "0", bar: 10, fill: 0},
+		{name: "no bar", total: "5", max: "10", bar: 0, fill: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r = row{side: book.Bids, level: book.Level{Price: decimal.NewFromInt(10), Size: decimal.NewFromInt(1)}, total: decimal.RequireFromString(tt.total)}
+			var line = level(&r, decimal.RequireFromString(tt.max), tt.bar)
+			if got := strings.Count(line, "█"); got != tt.fill {
+				t.Fatalf("bar = %d, want %d", got, tt.fill)
+			}
+			if !strings.HasPrefix(line, green) || !strings.HasSuffix(line, reset) {
+				t.Fatalf("line %q is not colored as a bid", line)
+			}
+		})
+	}
+}
+
+func TestPad(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{s: "ab", width: 4, want: "ab  "},
+		{s: "─│", width: 3, want: "─│ "},
+		{s: "abcd", width: 2, want: "abcd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if got := pad(tt.s, tt.width); got != tt.want {
+				t.Fatalf("pad = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
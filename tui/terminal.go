@@ -0,0 +1,120 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	keyUp        = "\033[A"
+	keyDown      = "\033[B"
+	keyRight     = "\033[C"
+	keyLeft      = "\033[D"
+	keyPageUp    = "\033[5~"
+	keyPageDown  = "\033[6~"
+	keyBackTab   = "\033[Z"
+	keyTab       = "\t"
+	keyInterrupt = "\003"
+)
+
+const (
+	enterScreen = "\033[?1049h\033[?25l\033[?7l"
+	leaveScreen = "\033[?7h\033[?25h\033[?1049l"
+	home        = "\033[H"
+	clearLine   = "\033[K"
+	clearBelow  = "\033[J"
+	reverse     = "\033[7m"
+	bold        = "\033[1m"
+	dim         = "\033[2m"
+	red         = "\033[31m"
+	green       = "\033[32m"
+	reset       = "\033[0m"
+)
+
+// terminal puts the controlling terminal in raw mode on the alternate screen through stty, which
+// keeps the package free of terminal libraries.
+type terminal struct {
+	in    *os.File
+	out   *os.File
+	state string
+}
+
+func stty(args ...string) (out string, err error) {
+	var cmd = exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	var b []byte
+	b, err = cmd.Output()
+	if err != nil {
+		err = fmt.Errorf("tui stty %s: %w", strings.Join(args, " "), err)
+		return
+	}
+	out = strings.TrimSpace(string(b))
+	return
+}
+
+func openTerminal() (term *terminal, err error) {
+	term = &terminal{in: os.Stdin, out: os.Stdout}
+	term.state, err = stty("-g")
+	if err != nil {
+		return
+	}
+	_, err = stty("raw", "-echo")
+	if err != nil {
+		return
+	}
+	_, err = term.out.WriteString(enterScreen)
+	return
+}
+
+func (term *terminal) close() {
+	_, _ = term.out.WriteString(leaveScreen)
+	_, _ = stty(term.state)
+}
+
+func (term *terminal) size() (rows, cols int, err error) {
+	var out string
+	out, err = stty("size")
+	if err != nil {
+		return
+	}
+	_, err = fmt.Sscan(out, &rows, &cols)
+	return
+}
+
+// read sends the keys typed on the terminal until it can no longer be read.
+func (term *terminal) read(keys chan<- string) {
+	defer close(keys)
+	var buffer = make([]byte, 64)
+	for {
+		n, err := term.in.Read(buffer)
+		if err != nil {
+			return
+		}
+		for _, key := range parseKeys(buffer[:n]) {
+			keys <- key
+		}
+	}
+}
+
+// parseKeys splits input into single characters and CSI escape sequences.
+func parseKeys(b []byte) (keys []string) {
+	for i := 0; i < len(b); {
+		if b[i] != '\033' || i+1 >= len(b) || b[i+1] != '[' {
+			keys = append(keys, string(b[i]))
+			i++
+			continue
+		}
+		var j = i + 2
+		for j < len(b) && (b[j] < 0x40 || b[j] > 0x7e) {
+			j++
+		}
+		if j < len(b) {
+			j++
+		}
+		keys = append(keys, string(b[i:j]))
+		i = j
+	}
+	return
+}
@@ -0,0 +1,28 @@
+package tui
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{input: "q", want: []string{"q"}},
+		{input: "+-12", want: []string{"+", "-", "1", "2"}},
+		{input: "\033[A\033[B", want: []string{"\033[A", "\033[B"}},
+		{input: "\033[5~c", want: []string{"\033[5~", "c"}},
+		{input: "\033", want: []string{"\033"}},
+		{input: "\033[1;", want: []string{"\033[1;"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%q", tt.input), func(t *testing.T) {
+			var got = parseKeys([]byte(tt.input))
+			if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+				t.Fatalf("keys = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
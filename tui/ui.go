@@ -0,0 +1,255 @@
+package tui
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/bzeron/mk/book"
+)
+
+var (
+	// FrameInterval is the shortest time between two redraws caused by book changes.
+	FrameInterval = 50 * time.Millisecond
+	// ResizeInterval is how often the terminal size is polled.
+	ResizeInterval = time.Second
+
+	ErrNoSymbols = errors.New("tui no symbols")
+
+	ten = decimal.NewFromInt(10)
+)
+
+type (
+	Options struct {
+		Symbols []string
+		// Levels is the number of levels loaded per side.
+		Levels int
+		// Tick aggregates prices into buckets of tick, bids down and asks up; zero shows every price.
+		Tick decimal.Decimal
+		// Trades is the number of trades kept per symbol for the trades panel.
+		Trades int
+	}
+
+	// UI is an interactive order book of the symbols of a Manager: a ladder with cumulative depth
+	// bars between a spread header and a recent trades panel. It redraws when the shown book changes,
+	// at most once per FrameInterval.
+	UI struct {
+		manager *book.Manager
+		symbols []string
+		books   []*book.L3
+		tapes   []*book.Tape
+		cancels []func()
+		current int
+		levels  int
+		tick    decimal.Decimal
+		scroll  int
+		trades  int
+		term    *terminal
+		rows    int
+		cols    int
+		feed    *book.Feed
+		unwatch func()
+		drawn   time.Time
+		stop    chan error
+	}
+)
+
+// New acquires the books of the symbols from manager. Close releases them.
+func New(manager *book.Manager, options Options) (ui *UI, err error) {
+	if len(options.Symbols) == 0 {
+		err = ErrNoSymbols
+		return
+	}
+	if options.Levels <= 0 {
+		options.Levels = 50
+	}
+	if options.Trades <= 0 {
+		options.Trades = 200
+	}
+	ui = &UI{
+		manager: manager,
+		levels:  options.Levels,
+		tick:    options.Tick,
+		trades:  options.Trades,
+		stop:    make(chan error, 1),
+	}
+	for _, symbol := range options.Symbols {
+		var l3 *book.L3
+		l3, err = manager.Acquire(symbol)
+		if err != nil {
+			ui.Close()
+			return
+		}
+		var tape = book.NewTape(0, options.Trades)
+		ui.symbols = append(ui.symbols, symbol)
+		ui.books = append(ui.books, l3)
+		ui.tapes = append(ui.tapes, tape)
+		ui.cancels = append(ui.cancels, l3.Observe(tape))
+	}
+	return
+}
+
+// Close releases the books acquired by New.
+func (ui *UI) Close() {
+	for i, symbol := range ui.symbols {
+		ui.cancels[i]()
+		_ = ui.manager.Release(symbol)
+	}
+	ui.symbols, ui.books, ui.tapes, ui.cancels = nil, nil, nil, nil
+}
+
+// Stop ends Run, which returns err.
+func (ui *UI) Stop(err error) {
+	select {
+	case ui.stop <- err:
+	default:
+	}
+}
+
+// Run draws the book until q is pressed or Stop is called, restoring the terminal on return.
+func (ui *UI) Run() (err error) {
+	ui.term, err = openTerminal()
+	if err != nil {
+		return
+	}
+	defer ui.term.close()
+	var keys = make(chan string, 16)
+	go ui.term.read(keys)
+	var resize = time.NewTicker(ResizeInterval)
+	defer resize.Stop()
+	ui.watch()
+	defer func() { ui.unwatch() }()
+	ui.resize()
+	ui.draw()
+	var frame <-chan time.Time
+	for {
+		select {
+		case err = <-ui.stop:
+			return
+		case <-ui.feed.C():
+			ui.feed.Drain()
+			if frame == nil {
+				frame = time.After(time.Until(ui.drawn.Add(FrameInterval)))
+			}
+		case <-frame:
+			frame = nil
+			ui.draw()
+		case key, ok := <-keys:
+			if !ok || !ui.key(key) {
+				return
+			}
+			ui.draw()
+		case <-resize.C:
+			if ui.resize() {
+				ui.draw()
+			}
+		}
+	}
+}
+
+// watch observes the current book for changes.
+func (ui *UI) watch() {
+	ui.feed = book.NewFeed(true)
+	ui.unwatch = ui.books[ui.current].Observe(ui.feed)
+}
+
+func (ui *UI) resize() (changed bool) {
+	rows, cols, err := ui.term.size()
+	if err != nil || (rows == ui.rows && cols == ui.cols) {
+		return
+	}
+	ui.rows, ui.cols = rows, cols
+	return true
+}
+
+func (ui *UI) switchTo(i int) {
+	i = (i + len(ui.symbols)) % len(ui.symbols)
+	if i == ui.current {
+		return
+	}
+	ui.unwatch()
+	ui.current = i
+	ui.scroll = 0
+	ui.watch()
+}
+
+// key handles a key press, returning false when the UI should quit.
+func (ui *UI) key(key string) bool {
+	var page = ui.rows / 2
+	switch key {
+	case "q", keyInterrupt:
+		return false
+	case keyTab, keyRight, "n":
+		ui.switchTo(ui.current + 1)
+	case keyBackTab, keyLeft, "p":
+		ui.switchTo(ui.current - 1)
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+		if i := int(key[0] - '1'); i < len(ui.symbols) {
+			ui.switchTo(i)
+		}
+	case keyUp, "k":
+		ui.scroll--
+	case keyDown, "j":
+		ui.scroll++
+	case keyPageUp:
+		ui.scroll -= page
+	case keyPageDown:
+		ui.scroll += page
+	case "c", " ":
+		ui.scroll = 0
+	case "+", "=":
+		ui.levels += 10
+	case "-", "_":
+		if ui.levels > 10 {
+			ui.levels -= 10
+		}
+	case "]":
+		ui.coarser()
+	case "[":
+		ui.finer()
+	}
+	return true
+}
+
+// coarser multiplies the aggregation tick by ten, starting from the price increment of the best
+// prices when the book is not aggregated.
+func (ui *UI) coarser() {
+	if ui.tick.Sign() > 0 {
+		ui.tick = ui.tick.Mul(ten)
+		return
+	}
+	var increment = ui.increment()
+	if increment.Sign() > 0 {
+		ui.tick = increment.Mul(ten)
+	}
+}
+
+// finer divides the aggregation tick by ten, down to no aggregation.
+func (ui *UI) finer() {
+	if ui.tick.Sign() <= 0 {
+		return
+	}
+	ui.tick = ui.tick.Div(ten)
+	if ui.tick.LessThanOrEqual(ui.increment()) {
+		ui.tick = decimal.Zero
+	}
+}
+
+// increment guesses the price increment of the current book from the decimals of its best prices.
+func (ui *UI) increment() decimal.Decimal {
+	var exp int32
+	var found bool
+	for _, side := range []string{book.Bids, book.Asks} {
+		ui.books[ui.current].Walk(side, func(level book.Level) bool {
+			if e := level.Price.Exponent(); !found || e < exp {
+				exp, found = e, true
+			}
+			return false
+		})
+	}
+	if !found {
+		return decimal.Zero
+	}
+	return decimal.New(1, exp)
+}